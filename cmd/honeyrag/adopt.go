@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// staleService describes how an already-running instance of service differs
+// from configs/.env, or returns "" if it isn't running or matches. Only vLLM
+// is tied to a setting honeyrag can check: the model it serves. Ollama
+// serves whatever it is asked for, so any instance answering /api/tags will do.
+func (m Model) staleService(ctx context.Context, service string) string {
+	if service != "vllm" {
+		return ""
+	}
+	body, ok := fetchHealth(ctx, m.healthURL("vllm"), m.config["vllmAPIKey"], m.probe.timeout)
+	if !ok {
+		return ""
+	}
+	served, ok := vllmServedModels(body)
+	if !ok || slices.Contains(served, m.config["model"]) {
+		return ""
+	}
+	return fmt.Sprintf("vLLM at %s is serving %s, but configs/.env asks for %s",
+		m.serviceURL("vllm", ""), strings.Join(served, ", "), m.config["model"])
+}
+
+// reuseService decides what to do about an instance of the service step
+// index starts that is already up when the step runs. It reports true when the
+// step can use it as is: a matching instance is adopted through its pid file
+// so that quitting and restarting manage it too. An instance with the wrong
+// config is stopped when the user agreed to restart it, and otherwise fails
+// the step. With --force-restart every instance honeyrag started is stopped
+// first. false means the step should start the service.
+func (m Model) reuseService(ctx context.Context, index int) (bool, error) {
+	svc, _ := lookupService(m.steps[index].Service)
+	service := svc.portKey
+	if m.remote(service) {
+		return true, m.checkRemote(ctx, index, svc)
+	}
+	if m.forceRestart {
+		if m.restartRunning(index, svc, "--force-restart") && m.dryRun {
+			return false, nil
+		}
+	}
+
+	if stale := m.staleService(ctx, service); stale != "" {
+		if !m.consent.restart {
+			return false, fmt.Errorf("%s. Re-run with --force-restart to replace it, or stop it with `honeyrag stop %s`", stale, svc.name)
+		}
+		if !m.restartRunning(index, svc, stale) {
+			return false, fmt.Errorf("%s, and it wasn't started by honeyrag (no pid file in logs/), so it can't be restarted. Stop it and retry", stale)
+		}
+	}
+
+	if !m.verifyService(ctx, service) {
+		return false, nil
+	}
+	line := "already running (not started by honeyrag)"
+	if pid, _, ok := runningPID(m.logsDir, svc); ok && m.dryRun {
+		m.showAction("adopt pid %d, already running", pid)
+		return true, nil
+	} else if ok {
+		if _, err := m.processes.adopt(svc.name, pid); err == nil {
+			line = fmt.Sprintf("already running, adopted pid %d", pid)
+		}
+	}
+	m.notifier.notify(logUpdateMsg{index: index, line: line, info: line, reused: true})
+	m.logStep(index, "%s", line)
+	return true, nil
+}
+
+// checkRemote verifies an instance of svc on another machine, which honeyrag
+// uses but never installs, starts or restarts.
+func (m Model) checkRemote(ctx context.Context, index int, svc service) error {
+	url := m.serviceURL(svc.portKey, "")
+	if stale := m.staleService(ctx, svc.portKey); stale != "" {
+		return fmt.Errorf("%s (%s). Change the model on that host or in configs/.env", stale, svc.hostEnv)
+	}
+	if !m.verifyService(ctx, svc.portKey) {
+		return fmt.Errorf("%s at %s (%s) is not reachable. Start it on that host and retry", svc.label, url, svc.hostEnv)
+	}
+	line := "using remote " + url
+	m.notifier.notify(logUpdateMsg{index: index, line: line, info: line, reused: true})
+	m.logStep(index, "%s", line)
+	return nil
+}
+
+// restartRunning stops the instance of svc recorded in its pid file, giving
+// reason in the step log. It reports whether there was one to stop.
+func (m Model) restartRunning(index int, svc service, reason string) bool {
+	pid, _, ok := runningPID(m.logsDir, svc)
+	if !ok {
+		return false
+	}
+	if m.dryRun {
+		m.showAction("stop pid %d and start it again (%s)", pid, reason)
+		return true
+	}
+	msg, err := stopService(filepath.Join(m.logsDir, svc.name+".pid"), svc.command, 10*time.Second)
+	if err != nil {
+		msg = err.Error()
+	}
+	line := fmt.Sprintf("restarting (%s): %s", reason, msg)
+	m.notifier.notify(logUpdateMsg{index: index, line: line})
+	m.logStep(index, "%s", line)
+	return err == nil
+}
+
+// confirmRestart asks on the terminal, before the TUI takes it over,
+// whether services running with a different config may be restarted. It
+// returns false without asking when there are none.
+func (m Model) confirmRestart() bool {
+	var stale []string
+	for _, svc := range m.activeServices() {
+		if s := m.staleService(context.Background(), svc.portKey); s != "" {
+			stale = append(stale, s)
+		}
+	}
+	if len(stale) == 0 {
+		return false
+	}
+	return confirm(strings.Join(stale, ".\n") + ".\nRestart with the new settings?")
+}
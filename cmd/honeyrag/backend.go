@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// HONEYRAG_LLM_BACKEND values: which server answers the LightRAG and agent
+// chat requests.
+const (
+	llmBackendVLLM   = "vllm"
+	llmBackendOllama = "ollama"
+	// llmBackendRemote uses an OpenAI-compatible API that is already
+	// running, at LLM_BINDING_HOST.
+	llmBackendRemote = "remote"
+)
+
+// Embedding backends (EMBEDDING_BACKEND).
+const (
+	embeddingBackendOllama = "ollama"
+	embeddingBackendVLLM   = "vllm"
+)
+
+// ollamaStepKeys are the steps that install, start and fill Ollama, dropped
+// when nothing uses it.
+var ollamaStepKeys = []string{"ollama-install", "ollama", "embedding"}
+
+// vllmEmbeddings reports whether LightRAG embeds with vLLM rather than
+// Ollama (EMBEDDING_BACKEND=vllm).
+func (m Model) vllmEmbeddings() bool {
+	return m.config["embeddingBackend"] == embeddingBackendVLLM
+}
+
+// embeddingURL is the OpenAI-compatible API LightRAG embeds with on the vLLM
+// embedding backend: EMBEDDING_BINDING_HOST, or the vLLM honeyrag serves.
+func (m Model) embeddingURL() string {
+	if host := m.config["embedHost"]; host != "" {
+		return strings.TrimSuffix(host, "/")
+	}
+	return m.serviceURL("vllm", "/v1")
+}
+
+// defaultLLMBackend is the backend used when HONEYRAG_LLM_BACKEND is unset:
+// the remote one if LLM_BINDING_HOST is set, Ollama on macOS, which has no
+// CUDA for vLLM unless that runs elsewhere (VLLM_HOST), and vLLM otherwise.
+func defaultLLMBackend(config map[string]string) string {
+	switch {
+	case config["llmHost"] != "":
+		return llmBackendRemote
+	case runtime.GOOS == "darwin" && config["vllmHost"] == "":
+		return llmBackendOllama
+	}
+	return llmBackendVLLM
+}
+
+// defaultOllamaLLM is the chat model the Ollama backend pulls unless
+// OLLAMA_LLM_MODEL says otherwise: one small enough for a CPU, except on
+// macOS, where Ollama runs on the GPU and llama3.2 (3B) is quick.
+func defaultOllamaLLM() string {
+	if runtime.GOOS == "darwin" {
+		return "llama3.2"
+	}
+	return "qwen2.5:1.5b"
+}
+
+// ollamaBackend reports whether the chat model is served by Ollama, in which
+// case vLLM isn't started at all.
+func (m Model) ollamaBackend() bool {
+	return m.config["llmBackend"] == llmBackendOllama
+}
+
+// remoteLLM reports whether the chat model is served by an endpoint honeyrag
+// doesn't manage (LLM_BINDING_HOST), so vLLM isn't started and no GPU is
+// needed.
+func (m Model) remoteLLM() bool {
+	return m.config["llmBackend"] == llmBackendRemote
+}
+
+// remoteLLMBase is the remote endpoint's base URL, e.g.
+// https://llm.example.com/v1.
+func (m Model) remoteLLMBase() string {
+	return strings.TrimSuffix(m.config["llmHost"], "/")
+}
+
+// chatModel is the model answering chat requests on the configured backend.
+func (m Model) chatModel() string {
+	switch m.config["llmBackend"] {
+	case llmBackendOllama:
+		return m.config["ollamaModel"]
+	case llmBackendRemote:
+		return m.config["llmModel"]
+	}
+	return m.config["model"]
+}
+
+// validateRemoteLLM checks LLM_BINDING_HOST when the remote backend uses it.
+func validateRemoteLLM(config map[string]string) error {
+	if config["llmBackend"] != llmBackendRemote {
+		return nil
+	}
+	host := config["llmHost"]
+	if host == "" {
+		return fmt.Errorf("HONEYRAG_LLM_BACKEND=%s needs LLM_BINDING_HOST, e.g. https://llm.example.com/v1", llmBackendRemote)
+	}
+	u, err := url.Parse(host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid LLM_BINDING_HOST=%q: expected an http(s) URL such as https://llm.example.com/v1", host)
+	}
+	return nil
+}
+
+// verifyLLMEndpoint stands in for the vLLM step on the remote backend: the
+// endpoint must answer /models, with LLM_API_KEY as the bearer token, and
+// list the model.
+func (m Model) verifyLLMEndpoint(ctx context.Context, index int) error {
+	base, model := m.remoteLLMBase(), m.chatModel()
+	if m.dryRun {
+		m.showAction("check that %s/models lists %s", base, model)
+		return nil
+	}
+	body, ok := fetchHealth(ctx, base+"/models", m.config["llmAPIKey"], m.probe.timeout)
+	if !ok {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("LLM endpoint %s/models (LLM_BINDING_HOST) did not answer, or refused LLM_API_KEY", base)
+	}
+	served, ok := vllmServedModels(body)
+	if !ok {
+		return fmt.Errorf("%s/models did not return an OpenAI-style model list; LLM_BINDING_HOST should end in /v1", base)
+	}
+	if !slices.Contains(served, model) {
+		return fmt.Errorf("LLM endpoint %s serves %s, not %s. Set LLM_MODEL to one of them", base, strings.Join(served, ", "), model)
+	}
+	line := fmt.Sprintf("%s at %s", model, base)
+	m.notifier.notify(logUpdateMsg{index: index, line: line, info: "serving " + line})
+	m.logStep(index, "using %s", line)
+	return nil
+}
+
+// remoteLLMConfigView is shown under the LLM step on the remote backend.
+func (m Model) remoteLLMConfigView() string {
+	return styles.config.Render(fmt.Sprintf("    Backend: remote | Model: %s | %s", m.chatModel(), m.remoteLLMBase()))
+}
+
+// activeServices returns the services this configuration runs: those with a
+// step that starts them, so neither vLLM on the Ollama backend nor anything
+// left out with --only, --without or --skip.
+func (m Model) activeServices() []service {
+	var active []service
+	for _, svc := range services {
+		if slices.ContainsFunc(m.steps, func(step Step) bool { return step.Service == svc.name && !step.External }) {
+			active = append(active, svc)
+		}
+	}
+	return active
+}
+
+// gpuPreflight fails fast when vLLM would start without a usable GPU,
+// rather than letting it sit out its startup timeout. Asking for the CPU
+// explicitly with VLLM_DEVICE=cpu skips the check.
+func (m Model) gpuPreflight() error {
+	if m.config["device"] != deviceCPU || m.config["deviceDetected"] == "" {
+		return nil
+	}
+	return fmt.Errorf("no usable NVIDIA GPU found (nvidia-smi is missing or fails). " +
+		"Set HONEYRAG_LLM_BACKEND=ollama to serve the LLM with Ollama instead, " +
+		"or VLLM_DEVICE=cpu if you have a CPU build of vLLM")
+}
+
+// pullChatModel stands in for the vLLM step on the Ollama backend.
+func (m Model) pullChatModel(ctx context.Context, index int) error {
+	return m.pullOllamaModels(ctx, index, []string{m.config["ollamaModel"]})
+}
+
+// serviceEnv returns the environment for LightRAG and the agent: honeyrag's
+// own, with every setting they share with it set to the value honeyrag
+// resolved, so that a --vllm-port or a model from configs/honeyrag.yaml
+// reaches them too, whatever services/lightrag/.env would fill in. Their
+// LLM client points at vLLM, at Ollama's OpenAI-compatible API on the
+// Ollama backend, or at LLM_BINDING_HOST on the remote one. Services on
+// another host (see serviceEndpoint) get their URLs.
+func (m Model) serviceEnv() []string {
+	env := os.Environ()
+	for _, svc := range services {
+		env = append(env, svc.portEnv+"="+m.port(svc.portKey))
+	}
+	if m.vllmEmbeddings() {
+		env = append(env,
+			"EMBEDDING_BINDING=openai",
+			"EMBEDDING_BINDING_HOST="+m.embeddingURL(),
+			"EMBEDDING_MODEL="+m.config["embedModel"],
+		)
+		if key := m.config["vllmAPIKey"]; key != "" && m.config["embedHost"] == "" {
+			env = append(env, "EMBEDDING_BINDING_API_KEY="+key)
+		}
+	} else {
+		// LightRAG embeds with the first of the models pulled.
+		env = append(env,
+			"EMBEDDING_BINDING=ollama",
+			"EMBEDDING_BINDING_HOST="+m.ollamaURL(""),
+			"EMBEDDING_MODEL="+m.embedModels()[0],
+		)
+	}
+	env = append(env,
+		"LIGHTRAG_URL="+m.serviceURL("lightrag", ""),
+		"LLM_MODEL="+m.chatModel(),
+		"VLLM_MODEL="+m.chatModel(),
+	)
+
+	switch {
+	case m.remoteLLM():
+		env = append(env,
+			"LLM_BINDING=openai",
+			"LLM_BINDING_HOST="+m.remoteLLMBase(),
+			"LLM_BASE_URL="+m.remoteLLMBase(),
+		)
+		if key := m.config["llmAPIKey"]; key != "" {
+			env = append(env, "LLM_BINDING_API_KEY="+key, "VLLM_API_KEY="+key)
+		}
+	case m.ollamaBackend():
+		env = append(env,
+			"LLM_BINDING=ollama",
+			"LLM_BINDING_HOST="+m.ollamaURL(""),
+			"LLM_BASE_URL="+m.ollamaURL("/v1"),
+		)
+	default:
+		env = append(env,
+			"LLM_BINDING=openai",
+			"LLM_BINDING_HOST="+m.serviceURL("vllm", "/v1"),
+			"LLM_BASE_URL="+m.serviceURL("vllm", "/v1"),
+		)
+		if key := m.config["vllmAPIKey"]; key != "" {
+			env = append(env, "LLM_BINDING_API_KEY="+key, "VLLM_API_KEY="+key)
+		}
+	}
+	return env
+}
+
+// ollamaLLMConfigView is shown under the LLM step on the Ollama backend.
+func (m Model) ollamaLLMConfigView() string {
+	return styles.config.Render(fmt.Sprintf("    Backend: Ollama | Model: %s", m.config["ollamaModel"]))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectMarker identifies the honeyrag checkout.
+const projectMarker = "pyproject.toml"
+
+// stepFiles lists, by step key, what steps need from the checkout besides
+// the marker. LightRAG is configured entirely through its environment and
+// needs nothing more.
+var stepFiles = map[string][]string{
+	"agent": {filepath.Join("services", "agno", "app.py")},
+}
+
+// findBaseDir returns the honeyrag checkout to run from: dir if given (from
+// --base-dir), else HONEYRAG_DIR, else the current directory or the nearest
+// parent of it containing pyproject.toml. An explicit directory must itself
+// contain the marker; it is not searched above.
+func findBaseDir(dir string) (string, error) {
+	source := "--base-dir"
+	if dir == "" {
+		dir, source = os.Getenv("HONEYRAG_DIR"), "HONEYRAG_DIR"
+	}
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", source, err)
+		}
+		if !hasProjectMarker(abs) {
+			return "", fmt.Errorf("%s=%s is not the honeyrag directory (no %s there)", source, abs, projectMarker)
+		}
+		return abs, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("getting current directory: %v", err)
+	}
+	for dir := cwd; ; {
+		if hasProjectMarker(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s in %s or any parent directory. Run this from the honeyrag directory, or point --base-dir or HONEYRAG_DIR at it", projectMarker, cwd)
+		}
+		dir = parent
+	}
+}
+
+func hasProjectMarker(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, projectMarker))
+	return err == nil
+}
+
+// checkCheckout reports everything the steps about to run need that is
+// missing from the checkout, so that an incomplete one fails before the
+// pipeline starts rather than at its last step.
+func (m Model) checkCheckout() error {
+	var missing []string
+	for _, step := range m.steps {
+		if !m.hasStep(step.Key) {
+			continue
+		}
+		for _, file := range stepFiles[step.Key] {
+			if _, err := os.Stat(filepath.Join(m.baseDir, file)); err != nil {
+				missing = append(missing, fmt.Sprintf("%s (needed by %s)", file, step.Name))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s is missing files honeyrag needs:\n  %s\nRestore them (git checkout -- services) or point --base-dir at a complete checkout",
+		m.baseDir, strings.Join(missing, "\n  "))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// browserClosedMsg is sent when the command opening an endpoint exits.
+type browserClosedMsg struct {
+	url string
+	err error
+}
+
+// endpointKey maps a number key on the done screen to the endpoint listed
+// under that number.
+func (m Model) endpointKey(key string) (endpoint, bool) {
+	n, err := strconv.Atoi(key)
+	list := m.endpoints()
+	if err != nil || len(key) != 1 || n < 1 || n > len(list) || !m.running() {
+		return endpoint{}, false
+	}
+	return list[n-1], true
+}
+
+// browserCommand builds the command that opens url in the default browser.
+func browserCommand(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		return exec.Command("xdg-open", url)
+	}
+}
+
+// openBrowser opens url in the default browser, suspending the TUI while
+// the opener runs.
+func openBrowser(url string) tea.Cmd {
+	cmd := browserCommand(url)
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		return func() tea.Msg { return browserClosedMsg{url: url, err: err} }
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg { return browserClosedMsg{url: url, err: err} })
+}
+
+// copyToClipboard puts s on the system clipboard. Without one (no xclip,
+// xsel or wl-copy, or over SSH) it asks the terminal to, with an OSC 52
+// escape sequence, which most terminals honour.
+func copyToClipboard(s string) {
+	if err := clipboard.WriteAll(s); err == nil {
+		return
+	}
+	seq := osc52.New(s)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	}
+	seq.WriteTo(os.Stderr)
+}
+
+// endpointLegend says what the endpoint keys do, e.g. "1-3 open an
+// endpoint in the browser, c then its number copies it".
+func (m Model) endpointLegend() string {
+	n := min(len(m.endpoints()), 9)
+	if n == 0 {
+		return ""
+	}
+	keys := "1"
+	if n > 1 {
+		keys = fmt.Sprintf("1-%d", n)
+	}
+	return keys + " open an endpoint in the browser, c then its number copies it"
+}
+
+// doneLegend is the footer of the done screen: every key that does
+// something there, one line per kind.
+func (m Model) doneLegend() string {
+	keys := "l logs" + glyphs.sep + glyphs.updown + " enter inspect" + glyphs.sep + "q stop all services"
+	if list := m.endpoints(); len(list) > 0 {
+		keys = "o open the " + list[0].label + glyphs.sep + keys
+	}
+	lines := []string{keys}
+	if legend := m.endpointLegend(); legend != "" {
+		lines = append(lines, legend)
+	}
+	lines = append(lines, m.restartLegend())
+	return strings.Join(lines, "\n  ")
+}
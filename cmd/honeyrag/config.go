@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startupTimeoutVars lists, per service, the environment variables its
+// startup timeout is read from (first one set wins) and the default.
+// vllmWarmup is the grace period for vLLM's first completion once it lists
+// the model, lightragReady that for LightRAG to become ready once healthy.
+var startupTimeoutVars = []struct {
+	service  string
+	vars     []string
+	fallback time.Duration
+}{
+	{"ollama", []string{"OLLAMA_STARTUP_TIMEOUT"}, 30 * time.Second},
+	{"vllm", []string{"VLLM_STARTUP_TIMEOUT"}, 5 * time.Minute},
+	{"vllmWarmup", []string{"VLLM_WARMUP_TIMEOUT"}, 2 * time.Minute},
+	{"lightrag", []string{"LIGHTRAG_STARTUP_TIMEOUT"}, 60 * time.Second},
+	{"lightragReady", []string{"LIGHTRAG_READY_TIMEOUT"}, 2 * time.Minute},
+	{"agent", []string{"AGNO_STARTUP_TIMEOUT", "AGENT_STARTUP_TIMEOUT"}, 30 * time.Second},
+}
+
+// loadStartupTimeouts reads how long to wait for each service to become
+// healthy, keyed by service name.
+func loadStartupTimeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, t := range startupTimeoutVars {
+		timeouts[t.service] = t.fallback
+		for _, name := range t.vars {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			d, err := parseTimeout(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s=%q: %v", name, value, err)
+			}
+			timeouts[t.service] = d
+			break
+		}
+	}
+	return timeouts, nil
+}
+
+// parseTimeout accepts a Go duration ("90s", "5m") or a bare number of
+// seconds.
+func parseTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		secs, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("expected a duration like 90s or 5m")
+		}
+		d = time.Duration(secs) * time.Second
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be greater than zero")
+	}
+	return d, nil
+}
+
+// defaultProbeTimeout limits each health check request unless
+// HEALTHCHECK_TIMEOUT says otherwise.
+const defaultProbeTimeout = 2 * time.Second
+
+// probeSettings controls how waitForHealthy polls a service while it starts.
+type probeSettings struct {
+	// interval is the time between checks and timeout the limit on each
+	// request.
+	interval time.Duration
+	timeout  time.Duration
+	// initialDelay gives a freshly started service a moment to bind its
+	// port before the first check.
+	initialDelay time.Duration
+}
+
+// loadProbeSettings reads HEALTHCHECK_INTERVAL, HEALTHCHECK_TIMEOUT and
+// HEALTHCHECK_INITIAL_DELAY; the last may be 0.
+func loadProbeSettings() (probeSettings, error) {
+	p := probeSettings{interval: time.Second, timeout: defaultProbeTimeout, initialDelay: 500 * time.Millisecond}
+	for _, v := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"HEALTHCHECK_INTERVAL", &p.interval},
+		{"HEALTHCHECK_TIMEOUT", &p.timeout},
+		{"HEALTHCHECK_INITIAL_DELAY", &p.initialDelay},
+	} {
+		value, ok := os.LookupEnv(v.name)
+		if !ok {
+			continue
+		}
+		if v.dst == &p.initialDelay && (value == "0" || value == "0s") {
+			p.initialDelay = 0
+			continue
+		}
+		d, err := parseTimeout(value)
+		if err != nil {
+			return p, fmt.Errorf("invalid %s=%q: %v", v.name, value, err)
+		}
+		*v.dst = d
+	}
+	return p, nil
+}
+
+// validateConfig checks the settings that would otherwise only fail once
+// the services they are passed to are running, some after loading for a
+// while, and reports every problem at once, each with the variable at fault
+// and where its value came from.
+func (m Model) validateConfig() error {
+	var problems []string
+	bad := func(value, problem string, names ...string) {
+		problems = append(problems, fmt.Sprintf("%s=%q (%s): %s", names[0], value, m.sources.source(names), problem))
+	}
+
+	// Remote services' ports are only used to reach them.
+	used := make(map[string]string)
+	for _, svc := range services {
+		port := m.port(svc.portKey)
+		if err := validatePort(port); err != nil {
+			bad(port, "must be a port number from 1 to 65535", svc.portEnv)
+			continue
+		}
+		if m.remote(svc.portKey) {
+			continue
+		}
+		if other, ok := used[port]; ok {
+			problems = append(problems, fmt.Sprintf("%s and %s are both %s: each service needs a port of its own", other, svc.portEnv, port))
+		}
+		used[port] = svc.portEnv
+	}
+
+	if _, err := strconv.ParseBool(m.config["autoPort"]); err != nil {
+		bad(m.config["autoPort"], "must be true or false", "HONEYRAG_AUTO_PORT")
+	}
+
+	if host := m.config["agentBind"]; host != "localhost" && net.ParseIP(host) == nil {
+		bad(host, "must be an IP address to listen on, e.g. 127.0.0.1, or 0.0.0.0 for every interface", "AGNO_HOST")
+	}
+
+	if util, err := strconv.ParseFloat(m.config["gpuUtil"], 64); err != nil || !(util > 0 && util <= 1) {
+		bad(m.config["gpuUtil"], "must be a fraction of GPU memory greater than 0 and at most 1, e.g. 0.8", "VLLM_GPU_MEMORY_UTILIZATION")
+	}
+	if n, err := strconv.Atoi(m.config["maxLen"]); err != nil || n < 1 {
+		bad(m.config["maxLen"], "must be a positive whole number of tokens, e.g. 2048", "VLLM_MAX_MODEL_LEN")
+	}
+
+	switch m.config["llmBackend"] {
+	case llmBackendVLLM:
+		if m.config["model"] == "" {
+			bad("", "must name the model to serve, e.g. Qwen/Qwen2.5-1.5B-Instruct", "VLLM_MODEL")
+		}
+	case llmBackendOllama:
+		if m.config["ollamaModel"] == "" {
+			bad("", "must name the model to pull, e.g. qwen2.5:1.5b", "OLLAMA_LLM_MODEL")
+		}
+	case llmBackendRemote:
+		if m.config["llmModel"] == "" {
+			bad("", "must name the model the endpoint serves", "LLM_MODEL", "VLLM_MODEL")
+		}
+		if err := validateRemoteLLM(m.config); err != nil {
+			problems = append(problems, err.Error())
+		}
+	default:
+		bad(m.config["llmBackend"], fmt.Sprintf("must be %s, %s or %s", llmBackendVLLM, llmBackendOllama, llmBackendRemote), "HONEYRAG_LLM_BACKEND")
+	}
+	if path := m.config["lightragReadyPath"]; path != "" && !strings.HasPrefix(path, "/") {
+		bad(path, "must be a path starting with /, or empty to skip the readiness check", "LIGHTRAG_READY_PATH")
+	}
+	switch m.config["embeddingBackend"] {
+	case embeddingBackendOllama:
+		if len(m.embedModels()) == 0 {
+			bad(m.config["embedModel"], "must name the embedding model, e.g. nomic-embed-text", "OLLAMA_EMBED_MODEL", "OLLAMA_EMBEDDING_MODEL", "EMBEDDING_MODEL")
+		}
+	case embeddingBackendVLLM:
+		if m.config["embedHost"] == "" && m.config["llmBackend"] != llmBackendVLLM {
+			bad(m.config["embeddingBackend"], "needs HONEYRAG_LLM_BACKEND=vllm, or EMBEDDING_BINDING_HOST set to a vLLM serving embeddings", "EMBEDDING_BACKEND")
+		}
+		if m.config["embedModel"] == "" || strings.Contains(m.config["embedModel"], ",") {
+			bad(m.config["embedModel"], "must name the one model vLLM embeds with", "EMBEDDING_MODEL")
+		}
+		if host := m.config["embedHost"]; host != "" && !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+			bad(host, "must be a URL such as http://192.168.1.20:8001/v1", "EMBEDDING_BINDING_HOST")
+		}
+	default:
+		bad(m.config["embeddingBackend"], fmt.Sprintf("must be %s or %s", embeddingBackendOllama, embeddingBackendVLLM), "EMBEDDING_BACKEND")
+	}
+	switch m.config["logMode"] {
+	case logModeAppend, logModeTimestamped, logModeTruncate, logModeRotate:
+	default:
+		bad(m.config["logMode"], "must be append, timestamped, truncate or rotate", "HONEYRAG_LOG_MODE")
+	}
+	if n, err := strconv.Atoi(m.config["logMaxSize"]); err != nil || n < 1 {
+		bad(m.config["logMaxSize"], "must be a positive number of megabytes, e.g. 100", "HONEYRAG_LOG_MAX_SIZE")
+	}
+	if n, err := strconv.Atoi(m.config["logKeep"]); err != nil || n < 0 {
+		bad(m.config["logKeep"], "must be how many old logs to keep, e.g. 3, or 0 for none", "HONEYRAG_LOG_KEEP")
+	}
+	if gb, err := strconv.ParseFloat(m.config["minFreeGB"], 64); err != nil || gb < 0 {
+		bad(m.config["minFreeGB"], "must be a number of gigabytes, e.g. 5, or 0 to skip the disk space check", "HONEYRAG_MIN_FREE_GB")
+	}
+	if dir := m.docsDir(); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			bad(m.config["docsDir"], "must be a folder of documents to upload to LightRAG", "HONEYRAG_DOCS_DIR")
+		}
+	}
+	for _, pattern := range strings.Split(m.config["docsIgnore"], ",") {
+		if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+			bad(m.config["docsIgnore"], fmt.Sprintf("%q is not a valid pattern", strings.TrimSpace(pattern)), "HONEYRAG_DOCS_IGNORE")
+			break
+		}
+	}
+	if _, err := vllmExtraArgs(m.config); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// consent records what the user agreed to install or download, through
+// --yes or the questions main asks before the TUI starts. Nothing big is
+// fetched without it. restart allows replacing services left running with
+// a different config; --yes doesn't imply it, --force-restart does.
+type consent struct {
+	uvInstall     bool
+	pythonInstall bool
+	ollamaInstall bool
+	modelDownload bool
+	restart       bool
+}
+
+// stdin is shared by every prompt so input typed ahead isn't lost to a
+// reader that is thrown away.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks question on the terminal and reports whether the answer was
+// yes. Anything else, including EOF, is no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gigabyte is the unit HONEYRAG_MIN_FREE_GB is given in.
+const gigabyte = 1 << 30
+
+// diskUse is a directory the pipeline writes a lot into, with the steps that
+// do.
+type diskUse struct {
+	dir   string
+	steps []string
+}
+
+// ollamaModelsDir returns where a locally started Ollama keeps its models:
+// OLLAMA_MODELS, or ~/.ollama/models.
+func ollamaModelsDir() string {
+	if dir := os.Getenv("OLLAMA_MODELS"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ollama", "models")
+}
+
+// diskUses lists the directories the selected steps fill: the checkout, for
+// the virtualenv uv sync builds, and Ollama's models directory, for the
+// models it pulls. vLLM's download into the Hugging Face cache is checked
+// against its actual size in modelPreflight.
+func (m Model) diskUses() []diskUse {
+	var deps, pulls []string
+	for _, step := range m.steps {
+		if step.Status == "skipped" || step.External {
+			continue
+		}
+		switch {
+		case step.Key == "deps":
+			deps = append(deps, step.Name)
+		case step.Key == "embedding", step.Key == "llm" && m.ollamaBackend():
+			pulls = append(pulls, step.Name)
+		}
+	}
+
+	var uses []diskUse
+	if len(deps) > 0 {
+		uses = append(uses, diskUse{dir: m.baseDir, steps: deps})
+	}
+	if dir := ollamaModelsDir(); dir != "" && len(pulls) > 0 && !m.ollamaRemote() {
+		uses = append(uses, diskUse{dir: dir, steps: pulls})
+	}
+	return uses
+}
+
+// checkDiskSpace fails when a directory the pipeline is about to fill has
+// less than HONEYRAG_MIN_FREE_GB free, rather than letting a model pull or
+// uv sync die halfway with a write error. A threshold of 0 turns it off.
+func (m Model) checkDiskSpace() error {
+	minGB, _ := strconv.ParseFloat(m.config["minFreeGB"], 64)
+	if minGB <= 0 {
+		return nil
+	}
+	min := int64(minGB * gigabyte)
+
+	var low []string
+	for _, use := range m.diskUses() {
+		free, err := freeSpace(existingParent(use.dir))
+		if err != nil || free >= min {
+			continue
+		}
+		low = append(low, fmt.Sprintf("  %s: %s free (needed by %s)", use.dir, formatBytes(free), strings.Join(use.steps, ", ")))
+	}
+	if len(low) == 0 {
+		return nil
+	}
+	return fmt.Errorf("less than %s GB of disk space left:\n%s\nFree up space, or lower HONEYRAG_MIN_FREE_GB (0 turns this check off)",
+		m.config["minFreeGB"], strings.Join(low, "\n"))
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// docsExtensions are the file types LightRAG's /documents/upload accepts.
+var docsExtensions = []string{
+	".txt", ".md", ".pdf", ".docx", ".pptx", ".xlsx", ".rtf", ".odt", ".epub",
+	".html", ".htm", ".tex", ".csv", ".json", ".xml", ".yaml", ".yml", ".log",
+}
+
+// docsUploadTimeout bounds one upload; LightRAG queues the file for indexing
+// and answers without waiting for it.
+const docsUploadTimeout = 2 * time.Minute
+
+// ingestedFile records, in logsDir, the hashes of the files uploaded so far,
+// so a file that is renamed but unchanged isn't sent again.
+const ingestedFile = ".honeyrag-ingested.json"
+
+// docsDir returns HONEYRAG_DOCS_DIR, relative to baseDir unless absolute.
+func (m Model) docsDir() string {
+	dir := m.config["docsDir"]
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(m.baseDir, dir)
+}
+
+// docsIgnored reports whether rel, a path under the documents folder, is
+// left out: hidden files and folders always are, as is anything whose path or
+// name matches a pattern in HONEYRAG_DOCS_IGNORE, a comma-separated list of
+// globs such as "drafts/*,*.tmp".
+func (m Model) docsIgnored(rel string) bool {
+	name := filepath.Base(rel)
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, pattern := range strings.Split(m.config["docsIgnore"], ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// docsFiles lists the supported, not ignored files under the documents
+// folder, relative to it.
+func (m Model) docsFiles() ([]string, error) {
+	root := m.docsDir()
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel == "." {
+			return nil
+		}
+		if m.docsIgnored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && slices.Contains(docsExtensions, strings.ToLower(filepath.Ext(path))) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// lightragRequest sends a request to LightRAG's API with LIGHTRAG_API_KEY,
+// which lightrag-server reads too, when it is set.
+func (m Model) lightragRequest(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, m.serviceURL("lightrag", path), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if key := os.Getenv("LIGHTRAG_API_KEY"); key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// indexedDocuments returns the file names LightRAG already has, whatever
+// state their indexing is in, except for those that failed.
+func (m Model) indexedDocuments(ctx context.Context) (map[string]bool, error) {
+	resp, err := m.lightragRequest(ctx, http.MethodGet, "/documents", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /documents returned %s", resp.Status)
+	}
+	var list struct {
+		Statuses map[string][]struct {
+			FilePath string `json:"file_path"`
+		} `json:"statuses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("GET /documents: %v", err)
+	}
+	names := make(map[string]bool)
+	for status, docs := range list.Statuses {
+		if strings.EqualFold(status, "failed") {
+			continue
+		}
+		for _, doc := range docs {
+			names[filepath.Base(doc.FilePath)] = true
+		}
+	}
+	return names, nil
+}
+
+// uploadDocument posts path to /documents/upload. duplicate is set when
+// LightRAG already had it.
+func (m Model) uploadDocument(ctx context.Context, path string) (duplicate bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return false, err
+	}
+	form.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, docsUploadTimeout)
+	defer cancel()
+	resp, err := m.lightragRequest(ctx, http.MethodPost, "/documents/upload", form.FormDataContentType(), &body)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Detail  any    `json:"detail"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	switch {
+	case resp.StatusCode != http.StatusOK && result.Detail != nil:
+		return false, fmt.Errorf("%s: %v", resp.Status, result.Detail)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("upload returned %s", resp.Status)
+	case result.Status == "duplicated":
+		return true, nil
+	case result.Status == "failure":
+		return false, fmt.Errorf("%s", result.Message)
+	}
+	return false, nil
+}
+
+// fileHash is the SHA-256 of the file at path, in hex.
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ingestDocs is the optional last step, run when HONEYRAG_DOCS_DIR is set:
+// it uploads the documents in that folder to LightRAG, leaving out those it
+// already has by name, or by content under another name. A file that fails
+// to upload is reported but doesn't fail the step.
+func (m Model) ingestDocs(ctx context.Context, index int) error {
+	dir := m.docsDir()
+	files, err := m.docsFiles()
+	if err != nil {
+		return fmt.Errorf("failed to read HONEYRAG_DOCS_DIR: %v", err)
+	}
+	if m.dryRun {
+		m.showAction("upload the %d supported files in %s that LightRAG doesn't have yet to %s", len(files), dir, m.serviceURL("lightrag", "/documents/upload"))
+		return nil
+	}
+
+	indexed, err := m.indexedDocuments(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list LightRAG's documents: %v", err)
+	}
+	ingestedPath := filepath.Join(m.logsDir, ingestedFile)
+	ingested := make(map[string]string)
+	if data, err := os.ReadFile(ingestedPath); err == nil {
+		json.Unmarshal(data, &ingested)
+	}
+
+	say := func(format string, args ...any) {
+		line := fmt.Sprintf(format, args...)
+		m.notifier.notify(logUpdateMsg{index: index, line: line})
+		m.logStep(index, "%s", line)
+	}
+	var uploaded, skipped, failed int
+	for i, rel := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.notifier.notify(logUpdateMsg{index: index, line: "uploading " + rel, info: fmt.Sprintf("%d/%d files: %s", i+1, len(files), rel)})
+
+		path := filepath.Join(dir, rel)
+		name := filepath.Base(rel)
+		hash, err := fileHash(path)
+		if err != nil {
+			say("%s %s: %v", glyphs.failed, rel, err)
+			failed++
+			continue
+		}
+		if indexed[name] || indexed[ingested[hash]] {
+			skipped++
+			continue
+		}
+		duplicate, err := m.uploadDocument(ctx, path)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			say("%s %s: %v", glyphs.failed, rel, err)
+			failed++
+			continue
+		case duplicate:
+			skipped++
+		default:
+			say("%s %s", glyphs.ok, rel)
+			uploaded++
+		}
+		ingested[hash] = name
+		indexed[name] = true
+	}
+	if data, err := json.MarshalIndent(ingested, "", "  "); err == nil {
+		os.WriteFile(ingestedPath, data, 0644)
+	}
+
+	summary := fmt.Sprintf("indexed %d documents, skipped %d", uploaded, skipped)
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed (see logs/honeyrag.log)", failed)
+	}
+	m.notifier.notify(logUpdateMsg{index: index, line: summary, info: summary})
+	m.logStep(index, "%s", summary)
+	return nil
+}
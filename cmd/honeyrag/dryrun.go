@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dry runs (--dry-run) go through the headless runner. The read-only checks
+// run as usual; steps that would change anything print what they would do
+// and count as done. Service output is never produced, so nothing is
+// written under logs/.
+
+// showCommand prints cmd fully resolved, as it would be started: program,
+// arguments with any API key hidden, working directory and the environment
+// variables it adds to honeyrag's own.
+func (m Model) showCommand(cmd *exec.Cmd) {
+	args := maskAPIKey(cmd.Args)
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$\\") {
+			args[i] = strconv.Quote(arg)
+		}
+	}
+	fmt.Printf("    $ %s\n", strings.Join(args, " "))
+
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	fmt.Printf("      in %s\n", dir)
+
+	inherited := os.Environ()
+	for _, kv := range cmd.Env {
+		if slices.Contains(inherited, kv) {
+			continue
+		}
+		fmt.Printf("      with %s\n", redactEnv(kv))
+	}
+}
+
+// showAction prints something a step would do other than run a command.
+func (m Model) showAction(format string, args ...any) {
+	fmt.Printf("    would %s\n", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// errorHintsFile, in configs/, adds to the built-in error hints.
+const errorHintsFile = "error-hints.json"
+
+// errorHint explains a failure recognised by its output: what went wrong in
+// plain words and what to change.
+type errorHint struct {
+	Pattern     string `json:"pattern"`
+	Explanation string `json:"explanation"`
+	Fix         string `json:"fix"`
+
+	re *regexp.Regexp
+}
+
+// builtinErrorHints covers the failures people hit most. Patterns are
+// matched case-insensitively.
+var builtinErrorHints = []errorHint{
+	{
+		Pattern:     `OutOfMemoryError|CUDA out of memory|No available memory for the cache blocks`,
+		Explanation: "The GPU ran out of memory loading the model or sizing its KV cache.",
+		Fix:         "Lower VLLM_GPU_MEMORY_UTILIZATION or VLLM_MAX_MODEL_LEN in configs/.env, pick a smaller VLLM_MODEL, or free VRAM used by other programs.",
+	},
+	{
+		Pattern:     `address already in use|Errno 98|Errno 48|only one usage of each socket address`,
+		Explanation: "Another program is already listening on the service's port.",
+		Fix:         "Stop it (`honeyrag stop` ends services left by an earlier run), or change the service's *_PORT in configs/.env.",
+	},
+	{
+		Pattern:     `401 Client Error|GatedRepoError|gated repo|Access to model .* is restricted|Repository Not Found`,
+		Explanation: "Hugging Face refused to hand out the model: it is gated, private or misspelled.",
+		Fix:         "Accept the model's licence on huggingface.co and set HF_TOKEN in configs/.env, or choose an open VLLM_MODEL.",
+	},
+	{
+		Pattern:     `No interpreter found for Python|No download found for request|requires-python|Python .* is not supported`,
+		Explanation: "uv could not find or download a Python version the project allows.",
+		Fix:         "Install one that pyproject.toml's requires-python accepts, e.g. `uv python install " + preferredPython + "`, and retry.",
+	},
+	{
+		Pattern:     `could not connect to (a running )?ollama|could not connect to ollama app|Error: could not connect`,
+		Explanation: "Ollama's server isn't running or isn't reachable at OLLAMA_HOST.",
+		Fix:         "Start it with `ollama serve` (or the Ollama app), or check OLLAMA_HOST and OLLAMA_PORT in configs/.env.",
+	},
+}
+
+// loadErrorHints returns the hints from configs/error-hints.json in baseDir,
+// which are tried first, followed by the built-in ones. The file is a JSON
+// array of {"pattern", "explanation", "fix"} objects.
+func loadErrorHints(baseDir string) ([]errorHint, error) {
+	var hints []errorHint
+	data, err := os.ReadFile(filepath.Join(baseDir, "configs", errorHintsFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &hints); err != nil {
+			return nil, fmt.Errorf("configs/%s: %v", errorHintsFile, err)
+		}
+		for i, hint := range hints {
+			if hint.Pattern == "" || hint.Explanation == "" {
+				return nil, fmt.Errorf("configs/%s: hint %d: pattern and explanation are required", errorHintsFile, i+1)
+			}
+		}
+	}
+	hints = append(hints, builtinErrorHints...)
+
+	for i := range hints {
+		re, err := regexp.Compile("(?i)" + hints[i].Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("configs/%s: hint %d: invalid pattern: %v", errorHintsFile, i+1, err)
+		}
+		hints[i].re = re
+	}
+	return hints, nil
+}
+
+// matchErrorHint returns the first hint whose pattern occurs in a failed
+// step's error, which for services ends with the tail of their log, or in
+// the output it captured. It returns nil if none does.
+func (m Model) matchErrorHint(index int, err error) *errorHint {
+	text := err.Error() + "\n" + strings.Join(m.steps[index].LogLines, "\n")
+	for i := range m.errorHints {
+		if m.errorHints[i].re.MatchString(text) {
+			return &m.errorHints[i]
+		}
+	}
+	return nil
+}
+
+// view renders the hint above a failure's raw output.
+func (h *errorHint) view() string {
+	s := styles.hint.Render(glyphs.hint + " " + h.Explanation)
+	if h.Fix != "" {
+		s += "\n" + styles.error.Render("   Fix: "+h.Fix)
+	}
+	return s
+}
+
+// text is the hint for plain output.
+func (h *errorHint) text() string {
+	s := "Hint: " + h.Explanation
+	if h.Fix != "" {
+		s += "\nFix: " + h.Fix
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The --output=json stream: one JSON object per line on stdout, each with
+// "event" saying which of the types below it is and "ts" when it happened.
+// Steps are named by their key (tools, ports, deps, ollama-install, ollama,
+// embedding, vllm or llm, lightrag, agent, docs). Fields are only ever
+// added, never renamed or removed.
+
+// eventHeader starts every event.
+type eventHeader struct {
+	Event string    `json:"event"`
+	TS    time.Time `json:"ts"`
+}
+
+// stepStartedEvent: a step started, or a service is being restarted.
+type stepStartedEvent struct {
+	eventHeader
+	Step string `json:"step"`
+}
+
+// stepLogEvent: a line of a step's output.
+type stepLogEvent struct {
+	eventHeader
+	Step string `json:"step"`
+	Line string `json:"line"`
+}
+
+// stepDoneEvent: a step finished. Reused is set when it found its service
+// already running.
+type stepDoneEvent struct {
+	eventHeader
+	Step       string `json:"step"`
+	DurationMS int64  `json:"duration_ms"`
+	Reused     bool   `json:"reused,omitempty"`
+}
+
+// stepErrorEvent: a step failed, or its service went down. LogTail is the
+// end of its log.
+type stepErrorEvent struct {
+	eventHeader
+	Step       string   `json:"step"`
+	DurationMS int64    `json:"duration_ms"`
+	Message    string   `json:"message"`
+	LogTail    []string `json:"log_tail"`
+}
+
+// stackReadyEvent: every step is done or skipped. Endpoints maps each
+// endpoint's name, as in the TUI's summary, to its URL.
+type stackReadyEvent struct {
+	eventHeader
+	DurationMS int64             `json:"duration_ms"`
+	Endpoints  map[string]string `json:"endpoints"`
+}
+
+// Event names.
+const (
+	eventStepStarted = "step_started"
+	eventStepLog     = "step_log"
+	eventStepDone    = "step_done"
+	eventStepError   = "step_error"
+	eventStackReady  = "stack_ready"
+)
+
+// eventLogTail is how many lines of a failed step's log step_error carries.
+const eventLogTail = 20
+
+func writeEvent(event any) {
+	json.NewEncoder(jsonOutput).Encode(event)
+}
+
+func newHeader(event string) eventHeader {
+	return eventHeader{Event: event, TS: time.Now().UTC()}
+}
+
+// emitStepEvent writes the event for step index's new status. Pending and
+// skipped steps have none.
+func (m Model) emitStepEvent(index int, err error) {
+	step := m.steps[index]
+	switch step.Status {
+	case "running":
+		writeEvent(stepStartedEvent{eventHeader: newHeader(eventStepStarted), Step: step.Key})
+	case "done":
+		writeEvent(stepDoneEvent{eventHeader: newHeader(eventStepDone), Step: step.Key,
+			DurationMS: step.elapsed().Milliseconds(), Reused: step.Reused})
+	case "error":
+		event := stepErrorEvent{eventHeader: newHeader(eventStepError), Step: step.Key, LogTail: step.LogLines}
+		if !step.StartedAt.IsZero() {
+			event.DurationMS = step.elapsed().Milliseconds()
+		}
+		if err != nil {
+			event.Message = err.Error()
+		}
+		if step.LogFile != "" {
+			if lines := readLastLines(filepath.Join(m.logsDir, step.LogFile), eventLogTail); lines != "" {
+				event.LogTail = strings.Split(lines, "\n")
+			}
+		}
+		if event.LogTail == nil {
+			event.LogTail = []string{}
+		}
+		writeEvent(event)
+	}
+}
+
+// emitLogEvent writes a line of step index's output.
+func (m Model) emitLogEvent(index int, line string) {
+	writeEvent(stepLogEvent{eventHeader: newHeader(eventStepLog), Step: m.steps[index].Key, Line: line})
+}
+
+// emitReadyEvent writes stack_ready with the endpoints.
+func (m Model) emitReadyEvent() {
+	endpoints := make(map[string]string)
+	for _, e := range m.endpoints() {
+		endpoints[e.label] = e.url
+	}
+	writeEvent(stackReadyEvent{eventHeader: newHeader(eventStackReady),
+		DurationMS: m.finishedAt.Sub(m.startedAt).Milliseconds(), Endpoints: endpoints})
+}
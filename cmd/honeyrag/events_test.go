@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureEvents sends the --output=json stream of m to a buffer for the
+// length of the test.
+func captureEvents(t *testing.T, m *Model) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	saved := jsonOutput
+	jsonOutput = &out
+	t.Cleanup(func() { jsonOutput = saved })
+	m.jsonStream, m.jsonEvents = true, true
+	return &out
+}
+
+// decodeEvents parses out line by line, each line strictly against the
+// struct its "event" names: no unknown fields, no missing ones.
+func decodeEvents(t *testing.T, out *bytes.Buffer) []any {
+	t.Helper()
+	var events []any
+	var last time.Time
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var header eventHeader
+		if err := json.Unmarshal(line, &header); err != nil {
+			t.Fatalf("not JSON: %s", line)
+		}
+		var event any
+		switch header.Event {
+		case eventStepStarted:
+			event = &stepStartedEvent{}
+		case eventStepLog:
+			event = &stepLogEvent{}
+		case eventStepDone:
+			event = &stepDoneEvent{}
+		case eventStepError:
+			event = &stepErrorEvent{}
+		case eventStackReady:
+			event = &stackReadyEvent{}
+		default:
+			t.Fatalf("unknown event %q: %s", header.Event, line)
+		}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(event); err != nil {
+			t.Fatalf("%s doesn't match its schema: %v", line, err)
+		}
+		checkFieldsPresent(t, line, event)
+
+		if header.TS.IsZero() || header.TS.Location() != time.UTC || header.TS.Before(last) {
+			t.Errorf("ts %v of %s is missing, not UTC or before the last event's", header.TS, line)
+		}
+		last = header.TS
+		// Keep the events comparable: drop the header and the durations.
+		events = append(events, normalize(event))
+	}
+	return events
+}
+
+// checkFieldsPresent fails unless line has every field of event's struct
+// that isn't omitempty.
+func checkFieldsPresent(t *testing.T, line []byte, event any) {
+	t.Helper()
+	var fields map[string]any
+	json.Unmarshal(line, &fields)
+	var check func(typ reflect.Type)
+	check = func(typ reflect.Type) {
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			if f.Anonymous {
+				check(f.Type)
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if _, ok := fields[name]; !ok && opts != "omitempty" {
+				t.Errorf("%s lacks %q", line, name)
+			}
+		}
+	}
+	check(reflect.TypeOf(event).Elem())
+}
+
+func normalize(event any) any {
+	switch e := event.(type) {
+	case *stepStartedEvent:
+		return stepStartedEvent{Step: e.Step}
+	case *stepLogEvent:
+		return stepLogEvent{Step: e.Step, Line: e.Line}
+	case *stepDoneEvent:
+		return stepDoneEvent{Step: e.Step, Reused: e.Reused}
+	case *stepErrorEvent:
+		return stepErrorEvent{Step: e.Step, Message: e.Message, LogTail: e.LogTail}
+	case *stackReadyEvent:
+		return stackReadyEvent{Endpoints: e.Endpoints}
+	}
+	return event
+}
+
+// loggingStep is a step that prints lines, as a service's output would
+// reach the TUI, and then returns err. reused reports the service as found
+// already running.
+func loggingStep(key string, err error, reused bool, lines ...string) Step {
+	step := testStep(key, succeed)
+	step.Run = func(m Model, ctx context.Context, index int) error {
+		for _, line := range lines {
+			m.notifier.notify(logUpdateMsg{index: index, line: line, reused: reused})
+		}
+		return err
+	}
+	return step
+}
+
+func TestEventStreamSuccess(t *testing.T) {
+	steps := []Step{
+		loggingStep("tools", nil, false, "uv 0.5.0"),
+		loggingStep("ports", nil, false),
+		loggingStep("ollama", nil, false),
+		loggingStep("lightrag", nil, true, "LightRAG already running on port 9621"),
+	}
+	steps[1].DependsOn = []string{"tools"}
+	steps[2].Status = "skipped"
+	steps[3].DependsOn = []string{"ports", "ollama"}
+	steps[3].Service = "lightrag"
+	m := testModel(t, steps)
+	out := captureEvents(t, &m)
+
+	m = runPipeline(t, m)
+	if !m.done {
+		t.Fatalf("pipeline failed: %v", m.err)
+	}
+
+	want := []any{
+		stepStartedEvent{Step: "tools"},
+		stepLogEvent{Step: "tools", Line: "uv 0.5.0"},
+		stepDoneEvent{Step: "tools"},
+		stepStartedEvent{Step: "ports"},
+		stepDoneEvent{Step: "ports"},
+		stepStartedEvent{Step: "lightrag"},
+		stepLogEvent{Step: "lightrag", Line: "LightRAG already running on port 9621"},
+		stepDoneEvent{Step: "lightrag", Reused: true},
+		stackReadyEvent{Endpoints: map[string]string{"LightRAG UI": "http://localhost:9621"}},
+	}
+	got := decodeEvents(t, out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", eventList(got), eventList(want))
+	}
+}
+
+func TestEventStreamFailure(t *testing.T) {
+	m := testModel(t, []Step{
+		loggingStep("tools", nil, false),
+		loggingStep("deps", errors.New("uv sync failed"), false, "resolving", "no solution found"),
+		loggingStep("lightrag", nil, false),
+	})
+	m.steps[1].DependsOn = []string{"tools"}
+	m.steps[2].DependsOn = []string{"deps"}
+	out := captureEvents(t, &m)
+
+	m = runPipeline(t, m)
+
+	want := []any{
+		stepStartedEvent{Step: "tools"},
+		stepDoneEvent{Step: "tools"},
+		stepStartedEvent{Step: "deps"},
+		stepLogEvent{Step: "deps", Line: "resolving"},
+		stepLogEvent{Step: "deps", Line: "no solution found"},
+		stepErrorEvent{Step: "deps", Message: "uv sync failed", LogTail: []string{"resolving", "no solution found"}},
+	}
+	got := decodeEvents(t, out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", eventList(got), eventList(want))
+	}
+}
+
+func eventList(events []any) string {
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "\t%T %+v\n", event, event)
+	}
+	return b.String()
+}
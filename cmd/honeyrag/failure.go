@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// failureFile is written under logs/ when a step fails, and removed when a
+// run succeeds, so a script can tell what went wrong without parsing output.
+const failureFile = "failure.json"
+
+// Failure classes, each with its own exit code.
+const (
+	// failureDependency: a required tool, Python dependency or free port
+	// is missing.
+	failureDependency = "dependency"
+	// failureInstall: Ollama or a model could not be installed or pulled.
+	failureInstall = "install"
+	// failureTimeout: a service did not become ready in time.
+	failureTimeout = "timeout"
+	// failureHealth: a service exited, answered wrongly or went down.
+	failureHealth = "health"
+)
+
+// failureExitCodes are the exit codes of the failure classes. 1 and 2 stay
+// general errors and bad usage, 130 an interrupt.
+var failureExitCodes = map[string]int{
+	failureDependency: 3,
+	failureInstall:    4,
+	failureTimeout:    5,
+	failureHealth:     6,
+}
+
+// timeoutError is a service that didn't become ready within its timeout.
+type timeoutError string
+
+func (e timeoutError) Error() string { return string(e) }
+
+// failureClass says what kind of failure err, the error of step index, is.
+func (m Model) failureClass(index int, err error) string {
+	var timeout timeoutError
+	step := m.steps[index]
+	switch {
+	case errors.As(err, &timeout):
+		return failureTimeout
+	case step.Service != "" || step.External:
+		return failureHealth
+	case slices.Contains([]string{"tools", "ports", "deps"}, step.Key):
+		return failureDependency
+	case step.Key == "ollama-install" || step.Key == "embedding":
+		return failureInstall
+	case step.Key == "llm" && m.ollamaBackend():
+		return failureInstall
+	}
+	return failureHealth
+}
+
+// failureExitCode is the exit code for a run that failed at step index.
+func (m Model) failureExitCode(index int, err error) int {
+	return failureExitCodes[m.failureClass(index, err)]
+}
+
+// failureReport is the contents of failureFile.
+type failureReport struct {
+	Step      string    `json:"step"`
+	Key       string    `json:"key"`
+	Number    int       `json:"number"`
+	Class     string    `json:"class"`
+	ExitCode  int       `json:"exit_code"`
+	Error     string    `json:"error"`
+	LogFile   string    `json:"log_file,omitempty"`
+	LastLines []string  `json:"last_lines"`
+	StartedAt time.Time `json:"started_at"`
+	FailedAt  time.Time `json:"failed_at"`
+	StepMS    int64     `json:"step_duration_ms"`
+	RunMS     int64     `json:"run_duration_ms"`
+}
+
+// writeFailure records in logs/failure.json why step index failed with err:
+// its class and exit code, the error, the last lines of its log (or of the
+// output it captured) and how long it and the run had taken.
+func (m Model) writeFailure(index int, err error) error {
+	step := m.steps[index]
+	failed := step.FinishedAt
+	if failed.IsZero() {
+		failed = time.Now()
+	}
+	report := failureReport{
+		Step:      step.Name,
+		Key:       step.Key,
+		Number:    index + 1,
+		Class:     m.failureClass(index, err),
+		ExitCode:  m.failureExitCode(index, err),
+		Error:     err.Error(),
+		LastLines: step.LogLines,
+		StartedAt: step.StartedAt,
+		FailedAt:  failed,
+	}
+	if step.LogFile != "" {
+		report.LogFile = filepath.Join(m.logsDir, step.LogFile)
+		if _, err := os.Stat(report.LogFile); err == nil {
+			report.LastLines = strings.Split(readLastLines(report.LogFile, 20), "\n")
+		}
+	}
+	if report.LastLines == nil {
+		report.LastLines = []string{}
+	}
+	if !step.StartedAt.IsZero() {
+		report.StepMS = failed.Sub(step.StartedAt).Milliseconds()
+	}
+	if !m.startedAt.IsZero() {
+		report.RunMS = failed.Sub(m.startedAt).Milliseconds()
+	}
+
+	data, jerr := json.MarshalIndent(report, "", "  ")
+	if jerr != nil {
+		return jerr
+	}
+	return os.WriteFile(filepath.Join(m.logsDir, failureFile), append(data, '\n'), 0644)
+}
+
+// clearFailure removes the failure report of an earlier run once the stack
+// is up.
+func (m Model) clearFailure() {
+	os.Remove(filepath.Join(m.logsDir, failureFile))
+}
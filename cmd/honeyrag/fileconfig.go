@@ -0,0 +1,454 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the optional YAML config read from configs/ along with
+// .env.
+const configFileName = "honeyrag.yaml"
+
+// fileConfig is the schema of configs/honeyrag.yaml. Every setting stands
+// for the environment variable in its env tag (the first name, when there
+// are aliases separated by |), and is applied as that variable unless the
+// environment already has it, so the rest of honeyrag and the services it
+// starts see it exactly like a .env value. check names any validation beyond
+// the field's type.
+type fileConfig struct {
+	Ports struct {
+		Ollama   int  `yaml:"ollama" env:"OLLAMA_PORT" check:"port"`
+		VLLM     int  `yaml:"vllm" env:"VLLM_PORT" check:"port"`
+		LightRAG int  `yaml:"lightrag" env:"LIGHTRAG_PORT" check:"port"`
+		Agent    int  `yaml:"agent" env:"AGNO_PORT" check:"port"`
+		Auto     bool `yaml:"auto" env:"HONEYRAG_AUTO_PORT"`
+	} `yaml:"ports"`
+
+	VLLM struct {
+		Model                string   `yaml:"model" env:"VLLM_MODEL"`
+		Host                 string   `yaml:"host" env:"VLLM_HOST"`
+		GPUMemoryUtilization float64  `yaml:"gpu_memory_utilization" env:"VLLM_GPU_MEMORY_UTILIZATION"`
+		MaxModelLen          int      `yaml:"max_model_len" env:"VLLM_MAX_MODEL_LEN"`
+		Device               string   `yaml:"device" env:"VLLM_DEVICE"`
+		TensorParallel       int      `yaml:"tensor_parallel" env:"VLLM_TENSOR_PARALLEL|VLLM_TENSOR_PARALLEL_SIZE"`
+		Quantization         string   `yaml:"quantization" env:"VLLM_QUANTIZATION"`
+		APIKey               string   `yaml:"api_key" env:"VLLM_API_KEY"`
+		ExtraArgs            []string `yaml:"extra_args" env:"VLLM_EXTRA_ARGS"`
+		StartupTimeout       string   `yaml:"startup_timeout" env:"VLLM_STARTUP_TIMEOUT" check:"duration"`
+		WarmupTimeout        string   `yaml:"warmup_timeout" env:"VLLM_WARMUP_TIMEOUT" check:"duration"`
+	} `yaml:"vllm"`
+
+	Ollama struct {
+		Host           string `yaml:"host" env:"OLLAMA_HOST"`
+		EmbedModel     string `yaml:"embed_model" env:"OLLAMA_EMBED_MODEL|OLLAMA_EMBEDDING_MODEL|EMBEDDING_MODEL"`
+		LLMModel       string `yaml:"llm_model" env:"OLLAMA_LLM_MODEL"`
+		StartupTimeout string `yaml:"startup_timeout" env:"OLLAMA_STARTUP_TIMEOUT" check:"duration"`
+	} `yaml:"ollama"`
+
+	Embedding struct {
+		Backend string `yaml:"backend" env:"EMBEDDING_BACKEND"`
+		Host    string `yaml:"host" env:"EMBEDDING_BINDING_HOST"`
+	} `yaml:"embedding"`
+
+	LightRAG struct {
+		Host           string `yaml:"host" env:"LIGHTRAG_HOST"`
+		StartupTimeout string `yaml:"startup_timeout" env:"LIGHTRAG_STARTUP_TIMEOUT" check:"duration"`
+		ReadyPath      string `yaml:"ready_path" env:"LIGHTRAG_READY_PATH"`
+		ReadyTimeout   string `yaml:"ready_timeout" env:"LIGHTRAG_READY_TIMEOUT" check:"duration"`
+	} `yaml:"lightrag"`
+
+	Agent struct {
+		Host           string `yaml:"host" env:"AGNO_HOST"`
+		StartupTimeout string `yaml:"startup_timeout" env:"AGNO_STARTUP_TIMEOUT|AGENT_STARTUP_TIMEOUT" check:"duration"`
+	} `yaml:"agent"`
+
+	LLM struct {
+		Backend string `yaml:"backend" env:"HONEYRAG_LLM_BACKEND"`
+		Host    string `yaml:"host" env:"LLM_BINDING_HOST"`
+		APIKey  string `yaml:"api_key" env:"LLM_API_KEY|LLM_BINDING_API_KEY"`
+		Model   string `yaml:"model" env:"LLM_MODEL"`
+	} `yaml:"llm"`
+
+	HealthCheck struct {
+		Interval     string `yaml:"interval" env:"HEALTHCHECK_INTERVAL" check:"duration"`
+		Timeout      string `yaml:"timeout" env:"HEALTHCHECK_TIMEOUT" check:"duration"`
+		InitialDelay string `yaml:"initial_delay" env:"HEALTHCHECK_INITIAL_DELAY" check:"delay"`
+	} `yaml:"healthcheck"`
+
+	Supervision struct {
+		Interval    string `yaml:"interval" env:"HONEYRAG_HEALTH_INTERVAL" check:"duration"`
+		AutoRestart bool   `yaml:"auto_restart" env:"HONEYRAG_AUTO_RESTART"`
+		MaxRestarts int    `yaml:"max_restarts" env:"HONEYRAG_MAX_RESTARTS"`
+	} `yaml:"supervision"`
+
+	Logs struct {
+		Mode    string `yaml:"mode" env:"HONEYRAG_LOG_MODE"`
+		MaxSize int    `yaml:"max_size" env:"HONEYRAG_LOG_MAX_SIZE"`
+		Keep    int    `yaml:"keep" env:"HONEYRAG_LOG_KEEP"`
+	} `yaml:"logs"`
+
+	Docs struct {
+		Dir    string   `yaml:"dir" env:"HONEYRAG_DOCS_DIR"`
+		Ignore []string `yaml:"ignore" env:"HONEYRAG_DOCS_IGNORE"`
+	} `yaml:"docs"`
+
+	MinFreeGB float64  `yaml:"min_free_gb" env:"HONEYRAG_MIN_FREE_GB"`
+	SkipSteps []string `yaml:"skip_steps" env:"HONEYRAG_SKIP_STEPS"`
+}
+
+// fileSetting is one value read from the config file, as the environment
+// variable it stands for.
+type fileSetting struct {
+	env   []string
+	value string
+	line  int
+}
+
+// loadFileConfig reads the config file at path, if there is one. Errors
+// name the file, line and key at fault.
+func loadFileConfig(path string) ([]fileSetting, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Join("configs", configFileName)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	var cfg fileConfig
+	var settings []fileSetting
+	if err := decodeSection(name, "", doc.Content[0], reflect.ValueOf(&cfg).Elem(), &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// decodeSection decodes the mapping node into the struct v, appending a
+// setting for every value found.
+func decodeSection(file, path string, node *yaml.Node, v reflect.Value, settings *[]fileSetting) error {
+	if node.Kind != yaml.MappingNode {
+		if path == "" {
+			return fmt.Errorf("%s:%d: expected sections such as vllm: and ports:", file, node.Line)
+		}
+		return fmt.Errorf("%s:%d: %s: expected a section of settings", file, node.Line, path)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		key := keyNode.Value
+		if path != "" {
+			key = path + "." + keyNode.Value
+		}
+		if seen[keyNode.Value] {
+			return fmt.Errorf("%s:%d: %s is set twice", file, keyNode.Line, key)
+		}
+		seen[keyNode.Value] = true
+
+		field, ok := yamlField(v.Type(), keyNode.Value)
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown key %s", file, keyNode.Line, key)
+		}
+		fv := v.FieldByIndex(field.Index)
+		env := field.Tag.Get("env")
+		if env == "" {
+			if err := decodeSection(file, key, valueNode, fv, settings); err != nil {
+				return err
+			}
+			continue
+		}
+		if valueNode.Tag == "!!null" {
+			continue
+		}
+		if err := valueNode.Decode(fv.Addr().Interface()); err != nil {
+			if valueNode.Kind == yaml.ScalarNode {
+				return fmt.Errorf("%s:%d: %s: expected %s, got %q", file, valueNode.Line, key, kindName(fv.Kind()), valueNode.Value)
+			}
+			return fmt.Errorf("%s:%d: %s: expected %s", file, valueNode.Line, key, kindName(fv.Kind()))
+		}
+		value := formatSetting(fv, env)
+		if err := checkSetting(field.Tag.Get("check"), value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", file, valueNode.Line, key, err)
+		}
+		*settings = append(*settings, fileSetting{env: strings.Split(env, "|"), value: value, line: valueNode.Line})
+	}
+	return nil
+}
+
+// yamlField finds the field of t whose yaml tag is name.
+func yamlField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Tag.Get("yaml") == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func kindName(k reflect.Kind) string {
+	switch k {
+	case reflect.Int:
+		return "a whole number"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list"
+	}
+	return "a single value"
+}
+
+// formatSetting renders a decoded value the way its environment variable
+// is written: lists are comma-separated, except VLLM_EXTRA_ARGS, which is
+// split like a command line.
+func formatSetting(v reflect.Value, env string) string {
+	switch v.Kind() {
+	case reflect.Int:
+		return strconv.Itoa(int(v.Int()))
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Slice:
+		items := v.Interface().([]string)
+		if env != "VLLM_EXTRA_ARGS" {
+			return strings.Join(items, ",")
+		}
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = "'" + strings.ReplaceAll(item, "'", `'\''`) + "'"
+		}
+		return strings.Join(quoted, " ")
+	}
+	return v.String()
+}
+
+func checkSetting(check, value string) error {
+	switch check {
+	case "port":
+		return validatePort(value)
+	case "delay":
+		if value == "0" || value == "0s" {
+			return nil
+		}
+		fallthrough
+	case "duration":
+		_, err := parseTimeout(value)
+		return err
+	}
+	return nil
+}
+
+// configSources records where settings came from, for `honeyrag config
+// show`. Everything is keyed by environment variable.
+type configSources struct {
+	env    map[string]bool
+	file   map[string]int
+	dotenv map[string]string
+	flags  map[string]string
+}
+
+// applyFileConfig sets the environment variables settings stand for, except
+// those the environment already has under any of their names. env holds the
+// variables set before configs/.env was loaded, so the file takes precedence
+// over .env but not over the real environment.
+func applyFileConfig(settings []fileSetting, env map[string]bool) map[string]int {
+	lines := make(map[string]int)
+	for _, s := range settings {
+		if setIn(env, s.env) {
+			continue
+		}
+		os.Setenv(s.env[0], s.value)
+		lines[s.env[0]] = s.line
+	}
+	return lines
+}
+
+func setIn(vars map[string]bool, names []string) bool {
+	for _, name := range names {
+		if vars[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// environSet returns the names of the variables currently set.
+func environSet() map[string]bool {
+	set := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		set[name] = true
+	}
+	return set
+}
+
+// source says where the variable known by names got its value.
+func (s configSources) source(names []string) string {
+	if flag, ok := s.flags[names[0]]; ok {
+		return flag
+	}
+	if setIn(s.env, names) {
+		return "environment"
+	}
+	if line, ok := s.file[names[0]]; ok {
+		return fmt.Sprintf("configs/%s:%d", configFileName, line)
+	}
+	for _, name := range names {
+		if _, ok := s.dotenv[name]; ok {
+			return "configs/.env"
+		}
+	}
+	return "default"
+}
+
+// resolvedConfig is the configuration m runs with, in the config file's
+// shape, with API keys hidden.
+func (m Model) resolvedConfig() fileConfig {
+	var c fileConfig
+	atoi := func(s string) int { n, _ := strconv.Atoi(s); return n }
+	secret := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "****"
+	}
+
+	c.Ports.Ollama = atoi(m.port("ollama"))
+	c.Ports.VLLM = atoi(m.port("vllm"))
+	c.Ports.LightRAG = atoi(m.port("lightrag"))
+	c.Ports.Agent = atoi(m.port("agno"))
+	c.Ports.Auto, _ = strconv.ParseBool(m.config["autoPort"])
+
+	c.VLLM.Model = m.config["model"]
+	c.VLLM.Host = m.config["vllmHost"]
+	c.VLLM.GPUMemoryUtilization, _ = strconv.ParseFloat(m.config["gpuUtil"], 64)
+	c.VLLM.MaxModelLen = atoi(m.config["maxLen"])
+	c.VLLM.Device = m.config["device"]
+	c.VLLM.TensorParallel = atoi(m.config["tensorParallel"])
+	c.VLLM.Quantization = m.config["quantization"]
+	c.VLLM.APIKey = secret(m.config["vllmAPIKey"])
+	c.VLLM.ExtraArgs, _ = splitArgs(m.config["vllmExtraArgs"])
+	c.VLLM.StartupTimeout = m.timeouts["vllm"].String()
+	c.VLLM.WarmupTimeout = m.timeouts["vllmWarmup"].String()
+
+	c.Ollama.Host = m.config["ollamaHost"]
+	c.Ollama.EmbedModel = m.config["embedModel"]
+	c.Ollama.LLMModel = m.config["ollamaModel"]
+	c.Ollama.StartupTimeout = m.timeouts["ollama"].String()
+
+	c.Embedding.Backend = m.config["embeddingBackend"]
+	c.Embedding.Host = m.config["embedHost"]
+
+	c.LightRAG.Host = m.config["lightragHost"]
+	c.LightRAG.StartupTimeout = m.timeouts["lightrag"].String()
+	c.LightRAG.ReadyPath = m.config["lightragReadyPath"]
+	c.LightRAG.ReadyTimeout = m.timeouts["lightragReady"].String()
+	c.Agent.Host = m.config["agentBind"]
+	c.Agent.StartupTimeout = m.timeouts["agent"].String()
+
+	c.LLM.Backend = m.config["llmBackend"]
+	c.LLM.Host = m.config["llmHost"]
+	c.LLM.APIKey = secret(m.config["llmAPIKey"])
+	c.LLM.Model = m.config["llmModel"]
+
+	c.HealthCheck.Interval = m.probe.interval.String()
+	c.HealthCheck.Timeout = m.probe.timeout.String()
+	c.HealthCheck.InitialDelay = m.probe.initialDelay.String()
+
+	c.Supervision.Interval = m.supervision.interval.String()
+	c.Supervision.AutoRestart = m.supervision.autoRestart
+	c.Supervision.MaxRestarts = m.supervision.maxRestarts
+
+	c.Logs.Mode = m.config["logMode"]
+	c.Logs.MaxSize = atoi(m.config["logMaxSize"])
+	c.Logs.Keep = atoi(m.config["logKeep"])
+
+	c.Docs.Dir = m.config["docsDir"]
+	for _, pattern := range strings.Split(m.config["docsIgnore"], ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			c.Docs.Ignore = append(c.Docs.Ignore, pattern)
+		}
+	}
+
+	c.MinFreeGB, _ = strconv.ParseFloat(m.config["minFreeGB"], 64)
+
+	for _, step := range m.steps {
+		if step.Status == "skipped" {
+			c.SkipSteps = append(c.SkipSteps, step.Key)
+		}
+	}
+	return c
+}
+
+// runConfig implements `honeyrag config show`: it prints the resolved
+// configuration as YAML, each setting commented with where it came from.
+func runConfig(m Model, args []string) int {
+	if len(args) != 1 || args[0] != "show" {
+		fmt.Println("Usage: honeyrag config show")
+		return 2
+	}
+
+	cfg := m.resolvedConfig()
+	var doc yaml.Node
+	if err := doc.Encode(&cfg); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	m.annotateSources(&doc, reflect.TypeOf(cfg))
+
+	fmt.Println("# Resolved configuration: flags > environment > configs/honeyrag.yaml > configs/.env > defaults")
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	enc.Close()
+	return 0
+}
+
+// annotateSources comments each setting in node, an encoded value of type
+// t, with its source.
+func (m Model) annotateSources(node *yaml.Node, t reflect.Type) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		field, ok := yamlField(t, keyNode.Value)
+		if !ok {
+			continue
+		}
+		env := field.Tag.Get("env")
+		if env == "" {
+			m.annotateSources(valueNode, field.Type)
+			continue
+		}
+		source := m.sources.source(strings.Split(env, "|"))
+		if env == "VLLM_DEVICE" && m.config["deviceDetected"] == "true" {
+			source = "detected"
+		}
+		if valueNode.Kind == yaml.SequenceNode {
+			// A block list starts on the next line, so comment the key.
+			if len(valueNode.Content) == 0 {
+				valueNode.Style = yaml.FlowStyle
+			}
+			keyNode.LineComment = source
+			continue
+		}
+		valueNode.LineComment = source
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+)
+
+// glyphSet holds the symbols the TUI draws with, so that terminals that
+// can't show them get plain ASCII instead.
+type glyphSet struct {
+	pending, done, failed, skipped string
+	// ok and failed also mark the files of a step that handles many.
+	ok      string
+	spinner spinner.Spinner
+	// barFull and barEmpty draw download progress bars.
+	barFull, barEmpty rune
+	// cursor marks the selected step; branch starts the line under a
+	// step, bar each of its log lines.
+	cursor, branch, bar string
+	honey, sparkle      string
+	warning, hint       string
+	// sep separates items in one line, dash a title from its subtitle and
+	// arrow an old value from a new one; updown names the arrow keys and
+	// caret is the text cursor.
+	sep, dash, arrow, updown, caret string
+}
+
+var unicodeGlyphs = glyphSet{
+	pending:  "○",
+	done:     "●",
+	failed:   "✗",
+	skipped:  "⊘",
+	ok:       "✓",
+	spinner:  spinner.Dot,
+	barFull:  '█',
+	barEmpty: '░',
+	cursor:   "▸ ",
+	branch:   "└─",
+	bar:      "│",
+	honey:    "🍯",
+	sparkle:  "✨",
+	warning:  "⚠",
+	hint:     "💡",
+	sep:      " · ",
+	dash:     " — ",
+	arrow:    "→",
+	updown:   "↑/↓",
+	caret:    "█",
+}
+
+var asciiGlyphs = glyphSet{
+	pending:  "[ ]",
+	done:     "[OK]",
+	failed:   "[X]",
+	skipped:  "[-]",
+	ok:       "[OK]",
+	spinner:  spinner.Spinner{Frames: []string{"[|]", "[/]", "[-]", "[\\]"}, FPS: spinner.Line.FPS},
+	barFull:  '#',
+	barEmpty: '-',
+	cursor:   "> ",
+	branch:   "`-",
+	bar:      "|",
+	honey:    "**",
+	sparkle:  "*",
+	warning:  "!",
+	hint:     "Hint:",
+	sep:      " | ",
+	dash:     " - ",
+	arrow:    "->",
+	updown:   "up/down",
+	caret:    "_",
+}
+
+// glyphs is what the TUI draws with: unicodeGlyphs unless --ascii is given
+// or the terminal can't show them.
+var glyphs = unicodeGlyphs
+
+// asciiTerminal reports whether the terminal is unlikely to show UTF-8: the
+// locale names another character set, or it is a dumb terminal or the Linux
+// console, whose font has no emoji. A locale that isn't set at all is taken to be UTF-8,
+// as it is in most terminals and containers.
+func asciiTerminal() bool {
+	if term := os.Getenv("TERM"); term == "linux" || term == "dumb" {
+		return true
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		locale := os.Getenv(name)
+		if locale == "" {
+			continue
+		}
+		locale = strings.ToLower(locale)
+		return !strings.Contains(locale, "utf-8") && !strings.Contains(locale, "utf8")
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	deviceCUDA = "cuda"
+	deviceCPU  = "cpu"
+)
+
+// detectDevice reports whether vLLM can use a CUDA GPU. nvidia-smi being on
+// PATH isn't enough on its own: it exits non-zero when the driver is missing
+// or no GPU is visible (e.g. in a container without --gpus). Without
+// nvidia-smi, the driver's /proc entries are the next best sign.
+func detectDevice() string {
+	path, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		if gpus, err := os.ReadDir("/proc/driver/nvidia/gpus"); err == nil && len(gpus) > 0 {
+			return deviceCUDA
+		}
+		return deviceCPU
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exec.CommandContext(ctx, path, "-L").Run(); err != nil {
+		return deviceCPU
+	}
+	return deviceCUDA
+}
+
+// vllmDeviceArgs returns the device-specific flags for vllm serve. The GPU
+// memory fraction is meaningless on CPU and makes the CPU backend refuse to
+// start, so it is only passed when serving from CUDA.
+func (m Model) vllmDeviceArgs(gpuUtil string) []string {
+	if m.config["device"] == deviceCPU {
+		return []string{"--device", deviceCPU}
+	}
+	return []string{"--gpu-memory-utilization", gpuUtil}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runHeadless runs the same steps as the TUI sequentially with plain,
+// line-oriented output for CI and servers without a terminal. It returns the
+// process exit code: that of the failure's class (see failureExitCodes) if a
+// step fails.
+func runHeadless(m Model) int {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Keep the latest status line of each step, which the TUI shows under
+	// it, for logs/summary.json.
+	var infoMu sync.Mutex
+	infos := make(map[int]string)
+	m.notifier.attach(func(msg tea.Msg) {
+		if update, ok := msg.(logUpdateMsg); ok && update.info != "" {
+			infoMu.Lock()
+			infos[update.index] = update.info
+			infoMu.Unlock()
+		}
+	})
+
+	m.startedAt = time.Now()
+	var deadline <-chan time.Time
+	if m.runTimeout > 0 {
+		deadline = time.After(m.runTimeout)
+	}
+	for i, step := range m.steps {
+		prefix := fmt.Sprintf("[%d/%d] %s", i+1, len(m.steps), step.Name)
+		if step.Status == "skipped" {
+			logf("%s: skipped", prefix)
+			m.logStep(i, "skipped")
+			continue
+		}
+		logf("%s: running", prefix)
+		m.logStep(i, "running")
+		m.startStep(i)
+		started := m.steps[i].StartedAt
+
+		result := make(chan error, 1)
+		go func() { result <- m.execStep(ctx, i) }()
+
+		select {
+		case err := <-result:
+			m.steps[i].FinishedAt = time.Now()
+			infoMu.Lock()
+			m.steps[i].Info = infos[i]
+			infoMu.Unlock()
+			elapsed := time.Since(started).Round(100 * time.Millisecond)
+			if err != nil {
+				logf("%s: failed after %s", prefix, elapsed)
+				m.logStep(i, "failed: %s", firstLine(err))
+				// Service failures already carry the tail of their log.
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				if hint := m.matchErrorHint(i, err); hint != nil {
+					fmt.Fprintln(os.Stderr, hint.text())
+				}
+				m.steps[i].Status = "error"
+				if !m.dryRun {
+					m.writeSummary(err)
+					m.writeFailure(i, err)
+				}
+				m.processes.stopAll(5 * time.Second)
+				return m.failureExitCode(i, err)
+			}
+			logf("%s: done in %s", prefix, elapsed)
+			m.steps[i].Status = "done"
+			m.logStep(i, "done")
+		case <-deadline:
+			cancel()
+			err := m.runTimeoutError()
+			logf("%s: %v", prefix, err)
+			m.logStep(i, "failed: %s", err)
+			m.steps[i].Status = "error"
+			m.steps[i].FinishedAt = time.Now()
+			if !m.dryRun {
+				m.writeSummary(err)
+				m.writeFailure(i, err)
+			}
+			logf("Stopping services...")
+			m.processes.stopAll(5 * time.Second)
+			return m.failureExitCode(i, err)
+		case <-sig:
+			cancel()
+			logf("%s: interrupted", prefix)
+			m.logStep(i, "interrupted")
+			logf("Stopping services...")
+			m.processes.stopAll(5 * time.Second)
+			return 130
+		}
+	}
+
+	if m.dryRun {
+		fmt.Println()
+		fmt.Println("Dry run: nothing was started.")
+		m.warnExposed()
+		return 0
+	}
+
+	m.finishedAt = time.Now()
+	m.saveState()
+	m.writeSummary(nil)
+	m.clearFailure()
+
+	fmt.Println()
+	fmt.Printf("All services running (started in %s):\n", formatElapsed(m.finishedAt.Sub(m.startedAt)))
+	for _, e := range m.endpoints() {
+		fmt.Printf("  %-14s%s\n", e.label+":", e.url)
+	}
+	m.warnExposed()
+	fmt.Println()
+	fmt.Println("Press Ctrl+C to stop all services")
+
+	<-sig
+	logf("Stopping services...")
+	m.processes.stopAll(5 * time.Second)
+	return 0
+}
+
+// logf prints a timestamped progress line to stdout.
+func logf(format string, args ...any) {
+	fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// warnExposed prints a warning for every service listening on more than
+// loopback.
+func (m Model) warnExposed() {
+	for _, e := range m.exposed() {
+		fmt.Printf("Warning: %s is reachable from other machines\n", e)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hfHubCache returns the Hugging Face hub cache directory, resolved the way
+// huggingface_hub does.
+func hfHubCache() string {
+	if dir := os.Getenv("HF_HUB_CACHE"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("HF_HOME"); dir != "" {
+		return filepath.Join(dir, "hub")
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "huggingface", "hub")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "huggingface", "hub")
+}
+
+// modelCached reports whether vLLM can load model without downloading it:
+// it is a local directory, or a snapshot in the hub cache has weights.
+func modelCached(model string) bool {
+	if info, err := os.Stat(model); err == nil && info.IsDir() {
+		return true
+	}
+	dir := filepath.Join(hfHubCache(), "models--"+strings.ReplaceAll(model, "/", "--"), "snapshots")
+	snapshots, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, snapshot := range snapshots {
+		for _, pattern := range []string{"*.safetensors", "*.bin"} {
+			if matches, _ := filepath.Glob(filepath.Join(dir, snapshot.Name(), pattern)); len(matches) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modelDownloadSize asks the Hugging Face API how many bytes vLLM will
+// download for model: the safetensors weights (or the .bin ones when there
+// are none) plus the config and tokenizer files.
+func modelDownloadSize(ctx context.Context, model string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	body, ok := fetchHealth(ctx, "https://huggingface.co/api/models/"+model+"?blobs=true", os.Getenv("HF_TOKEN"), defaultProbeTimeout)
+	if !ok {
+		return 0, fmt.Errorf("could not look up %s on huggingface.co", model)
+	}
+	var info struct {
+		Siblings []struct {
+			Name string `json:"rfilename"`
+			Size int64  `json:"size"`
+		} `json:"siblings"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return 0, err
+	}
+
+	var safetensors, bin, other int64
+	for _, file := range info.Siblings {
+		switch filepath.Ext(file.Name) {
+		case ".safetensors":
+			safetensors += file.Size
+		case ".bin", ".pt":
+			bin += file.Size
+		case ".json", ".txt", ".model", ".tiktoken", ".py":
+			other += file.Size
+		}
+	}
+	if safetensors > 0 {
+		return safetensors + other, nil
+	}
+	return bin + other, nil
+}
+
+// modelDownload describes a VLLM_MODEL download that the next vLLM start
+// would trigger.
+type modelDownload struct {
+	// size is the expected download in bytes, or 0 if it couldn't be
+	// determined.
+	size int64
+	// dir is the cache it goes to and free the space left there, or -1 if
+	// unknown.
+	dir  string
+	free int64
+}
+
+// pendingModelDownload returns the download vLLM would have to do before it
+// can serve, or nil if the model is already cached.
+func (m Model) pendingModelDownload(ctx context.Context) *modelDownload {
+	model := m.config["model"]
+	if modelCached(model) {
+		return nil
+	}
+	dl := &modelDownload{dir: hfHubCache(), free: -1}
+	dl.size, _ = modelDownloadSize(ctx, model)
+	if free, err := freeSpace(existingParent(dl.dir)); err == nil {
+		dl.free = free
+	}
+	return dl
+}
+
+// describe renders the download for prompts and errors, e.g.
+// "~15.2 GB to /home/me/.cache/huggingface/hub (120.5 GB free)".
+func (dl *modelDownload) describe() string {
+	size := "an unknown amount"
+	if dl.size > 0 {
+		size = "~" + formatBytes(dl.size)
+	}
+	s := size + " to " + dl.dir
+	if dl.free >= 0 {
+		s += " (" + formatBytes(dl.free) + " free)"
+	}
+	return s
+}
+
+// modelPreflight runs before vLLM starts: a model that still has to be
+// downloaded needs consent and room on disk, rather than filling the disk and
+// surfacing as a startup timeout.
+func (m Model) modelPreflight(ctx context.Context) error {
+	dl := m.pendingModelDownload(ctx)
+	if dl == nil {
+		return nil
+	}
+	if dl.size > 0 && dl.free >= 0 && dl.free < dl.size {
+		return fmt.Errorf("not enough disk space to download %s: needs %s. Free some space or set HF_HOME to a bigger disk",
+			m.config["model"], dl.describe())
+	}
+	if !m.consent.modelDownload {
+		return fmt.Errorf("%s is not downloaded yet; it needs %s. Re-run with --yes to download it",
+			m.config["model"], dl.describe())
+	}
+	return nil
+}
+
+// confirmModelDownload asks on the terminal, before the TUI takes it over,
+// whether VLLM_MODEL may be downloaded, if it isn't cached.
+func (m Model) confirmModelDownload() bool {
+	if m.remote("vllm") {
+		return false
+	}
+	dl := m.pendingModelDownload(context.Background())
+	if dl == nil {
+		return false
+	}
+	return confirm(fmt.Sprintf("%s is not downloaded yet. Download %s?", m.config["model"], dl.describe()))
+}
+
+// existingParent returns dir or its closest ancestor that exists, so free
+// space can be measured before the cache is created.
+func existingParent(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// hfProgressPattern matches the tqdm bars huggingface_hub prints per file,
+// e.g. "model-00001-of-00004.safetensors:  45%|████▌     | 2.24G/4.97G [...]".
+var hfProgressPattern = regexp.MustCompile(`^\s*(\S+?):\s+\d+%\|[^|]*\|\s*([\d.]+)([kMGTP]?)B?/([\d.]+)([kMGTP]?)B?\s`)
+
+// hfProgress adds up the per-file download bars in vLLM's output. Files
+// download in parallel, so each bar only updates its own file's share.
+type hfProgress struct {
+	files map[string][2]int64
+}
+
+// update parses line and reports the total bytes completed and expected
+// across every file seen so far, if line was a download bar.
+func (p *hfProgress) update(line string) (completed, total int64, ok bool) {
+	match := hfProgressPattern.FindStringSubmatch(line)
+	if match == nil {
+		return 0, 0, false
+	}
+	if p.files == nil {
+		p.files = make(map[string][2]int64)
+	}
+	p.files[match[1]] = [2]int64{siSize(match[2], match[3]), siSize(match[4], match[5])}
+	for _, file := range p.files {
+		completed += file[0]
+		total += file[1]
+	}
+	return completed, total, total > 0
+}
+
+// siSize converts a tqdm unit-scaled number such as 2.24 with prefix G to
+// bytes.
+func siSize(number, prefix string) int64 {
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+	if prefix != "" {
+		for range strings.Index("kMGTP", prefix) + 1 {
+			n *= 1000
+		}
+	}
+	return int64(n)
+}
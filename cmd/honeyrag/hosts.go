@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// serviceEndpoint splits the host configured for service (a Model.ports
+// key), e.g. OLLAMA_HOST or VLLM_HOST, into scheme, host and port. It reads
+// them the way the ollama CLI reads OLLAMA_HOST: the scheme defaults to http
+// and the port to the service's port. No host, or a wildcard bind address
+// such as 0.0.0.0, means this machine.
+func (m Model) serviceEndpoint(service string) (scheme, host, port string) {
+	scheme, host, port = "http", m.localHost(service), m.port(service)
+	svc, ok := serviceByPortKey(service)
+	if !ok || svc.hostKey == "" {
+		return scheme, host, port
+	}
+	raw := m.config[svc.hostKey]
+	if raw == "" {
+		return scheme, host, port
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return scheme, host, port
+	}
+	scheme = u.Scheme
+	if h := u.Hostname(); h != "0.0.0.0" && h != "::" {
+		host = h
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return scheme, host, port
+}
+
+// serviceURL returns the URL of path on service.
+func (m Model) serviceURL(service, path string) string {
+	scheme, host, port := m.serviceEndpoint(service)
+	return scheme + "://" + net.JoinHostPort(host, port) + path
+}
+
+// remote reports whether the host of service (a Model.ports key) is another
+// machine, in which case the launcher neither installs nor starts it, and
+// only checks that it answers.
+func (m Model) remote(service string) bool {
+	if svc, ok := serviceByPortKey(service); !ok || svc.hostKey == "" || m.config[svc.hostKey] == "" {
+		return false
+	}
+	_, host, _ := m.serviceEndpoint(service)
+	switch host {
+	case "localhost", "127.0.0.1", "::1":
+		return false
+	}
+	return true
+}
+
+// bindHost is the address a service honeyrag starts listens on: loopback
+// only, unless the agent's AGNO_HOST says otherwise, or every interface for
+// the agent, LightRAG and vLLM with --expose. Ollama is only used by the
+// other services and always stays on loopback.
+func (m Model) bindHost(service string) string {
+	switch {
+	case service == "ollama":
+		return "127.0.0.1"
+	case m.expose:
+		return "0.0.0.0"
+	case service == "agno" && m.config["agentBind"] != "":
+		return m.config["agentBind"]
+	}
+	return "127.0.0.1"
+}
+
+// localHost is the host to reach a service honeyrag runs itself at: the
+// address it listens on, or localhost when that is loopback or every
+// interface.
+func (m Model) localHost(service string) string {
+	host := m.bindHost(service)
+	if loopback(host) || host == "0.0.0.0" || host == "::" {
+		return "localhost"
+	}
+	return host
+}
+
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// exposed lists the services this run starts that listen on more than
+// loopback, as "Agent on 0.0.0.0:8081".
+func (m Model) exposed() []string {
+	var list []string
+	for _, svc := range services {
+		if svc.name == "ollama" || !m.hasStep(svc.name) || m.remote(svc.portKey) {
+			continue
+		}
+		if host := m.bindHost(svc.portKey); !loopback(host) {
+			list = append(list, svc.label+" on "+net.JoinHostPort(host, m.port(svc.portKey)))
+		}
+	}
+	return list
+}
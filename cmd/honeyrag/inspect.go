@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// inspectLogLines is how much of a step's log its details show.
+const inspectLogLines = 5
+
+// moveSelection moves the step cursor by delta, showing it on the first
+// arrow press: on the step 'l' would open, or the first one.
+func (m *Model) moveSelection(delta int) {
+	if m.selected < 0 {
+		m.selected = max(m.activeLogStep(), 0)
+		return
+	}
+	m.selected = min(max(m.selected+delta, 0), len(m.steps)-1)
+}
+
+// updateSelection handles the keys that move the step cursor and open its
+// details. It reports whether it used the key.
+func (m Model) updateSelection(msg tea.KeyMsg) (Model, bool) {
+	switch msg.String() {
+	case "up", "k":
+		m.moveSelection(-1)
+	case "down", "j":
+		m.moveSelection(1)
+	case "enter":
+		if m.selected < 0 {
+			return m, false
+		}
+		m.inspecting = !m.inspecting
+	case "esc":
+		if m.inspecting {
+			m.inspecting = false
+		} else {
+			m.selected = -1
+		}
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// focusedStep is the step 'l' and 'o' act on: the selected one, otherwise
+// whichever activeLogStep picks.
+func (m Model) focusedStep() int {
+	if m.selected >= 0 {
+		return m.selected
+	}
+	return m.activeLogStep()
+}
+
+// command describes the live process started for service name, e.g.
+// "pid 4242: uv run vllm serve ...". It is empty if there is none.
+func (g *processGroup) command(name string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := len(g.procs) - 1; i >= 0; i-- {
+		proc := g.procs[i]
+		if proc.name != name || proc.exited() || proc.cmd.Process == nil {
+			continue
+		}
+		if len(proc.cmd.Args) == 0 {
+			return fmt.Sprintf("pid %d (started by an earlier honeyrag)", proc.cmd.Process.Pid)
+		}
+		return fmt.Sprintf("pid %d: %s", proc.cmd.Process.Pid, strings.Join(proc.cmd.Args, " "))
+	}
+	return ""
+}
+
+// stepDetails renders what enter shows under the selected step: its status
+// and timing, the process it runs, where it logs, what it waits for and the
+// end of its log.
+func (m Model) stepDetails(index int) string {
+	step := m.steps[index]
+
+	status := step.Status
+	switch {
+	case step.StartedAt.IsZero():
+	case step.FinishedAt.IsZero():
+		status += fmt.Sprintf(" for %s, since %s", formatElapsed(step.elapsed()), step.StartedAt.Format("15:04:05"))
+	default:
+		status += fmt.Sprintf(" after %s, at %s", formatElapsed(step.elapsed()), step.FinishedAt.Format("15:04:05"))
+	}
+	rows := [][2]string{{"status", status}}
+	if step.Service != "" {
+		if cmd := m.processes.command(step.Service); cmd != "" {
+			rows = append(rows, [2]string{"command", cmd})
+		}
+	}
+	if step.LogFile != "" {
+		rows = append(rows, [2]string{"log", filepath.Join(m.logsDir, step.LogFile)})
+	}
+	if len(step.DependsOn) > 0 {
+		rows = append(rows, [2]string{"after", strings.Join(step.DependsOn, ", ")})
+	}
+
+	// Steps without a log file of their own, or that haven't written it
+	// yet, show the lines they captured.
+	tail := step.LogLines
+	if _, err := os.Stat(m.stepLogPath(index)); step.LogFile != "" && err == nil {
+		tail = nil
+		if lines := readLastLines(m.stepLogPath(index), inspectLogLines); lines != "" {
+			tail = strings.Split(lines, "\n")
+		}
+	}
+
+	var b strings.Builder
+	width := m.logLineWidth()
+	for _, row := range rows {
+		b.WriteString(styles.dim.Render(fmt.Sprintf("    %s %-9s%s", glyphs.bar, row[0]+":", truncate(row[1], width-9))))
+		b.WriteString("\n")
+	}
+	if len(tail) == 0 {
+		b.WriteString(styles.dim.Render("    " + glyphs.bar + " (no output yet)"))
+		b.WriteString("\n")
+	}
+	b.WriteString(m.logLinesView(tail))
+	return b.String()
+}
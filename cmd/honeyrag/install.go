@@ -0,0 +1,412 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ollamaReleaseURL is where the official release archives and their
+// sha256sum.txt are published.
+const ollamaReleaseURL = "https://github.com/ollama/ollama/releases/latest/download/"
+
+// ollamaArchive names the release archive for this OS and architecture.
+func ollamaArchive() (string, bool) {
+	switch runtime.GOOS {
+	case "linux":
+		if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+			return "ollama-linux-" + runtime.GOARCH + ".tgz", true
+		}
+	case "darwin":
+		return "ollama-darwin.tgz", true
+	case "windows":
+		if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+			return "ollama-windows-" + runtime.GOARCH + ".zip", true
+		}
+	}
+	return "", false
+}
+
+// userPrefix is where Ollama is installed without root: the binary goes in
+// ~/.local/bin and its libraries in ~/.local/lib/ollama, where it looks for
+// them relative to itself.
+func userPrefix() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local"), nil
+}
+
+// ollamaInstallDir returns where installOllama unpacks the release and the
+// path of the binary it holds: userPrefix on Unix, and on Windows
+// %LOCALAPPDATA%\Programs\Ollama, where OllamaSetup.exe installs it too.
+func ollamaInstallDir() (dir, binary string, err error) {
+	if runtime.GOOS == "windows" {
+		local := os.Getenv("LOCALAPPDATA")
+		if local == "" {
+			return "", "", errors.New("LOCALAPPDATA is not set")
+		}
+		dir = filepath.Join(local, "Programs", "Ollama")
+		return dir, filepath.Join(dir, "ollama.exe"), nil
+	}
+	prefix, err := userPrefix()
+	if err != nil {
+		return "", "", err
+	}
+	return prefix, filepath.Join(prefix, "bin", "ollama"), nil
+}
+
+// ollamaBinary returns the absolute path of the ollama executable to run:
+// the one on PATH, or failing that one in a usual install location, which a
+// shell started from the desktop (or the macOS app) may not have on PATH. It
+// returns "" if there is none.
+func ollamaBinary() string {
+	if path, err := exec.LookPath("ollama"); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+	for _, path := range ollamaInstallPaths() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// ollamaInstallPaths lists where Ollama's installers, Homebrew and earlier
+// runs of honeyrag (under userPrefix) put the binary, in the order they are
+// tried.
+func ollamaInstallPaths() []string {
+	var paths []string
+	if prefix, err := userPrefix(); err == nil {
+		paths = append(paths, filepath.Join(prefix, "bin", "ollama"))
+	}
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "darwin":
+		paths = append(paths,
+			"/usr/local/bin/ollama",
+			"/opt/homebrew/bin/ollama",
+			"/Applications/Ollama.app/Contents/Resources/ollama",
+		)
+		if home != "" {
+			paths = append(paths, filepath.Join(home, "Applications", "Ollama.app", "Contents", "Resources", "ollama"))
+		}
+	case "windows":
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			paths = append(paths, filepath.Join(local, "Programs", "Ollama", "ollama.exe"))
+		}
+	default:
+		paths = append(paths, "/usr/local/bin/ollama", "/usr/bin/ollama", "/home/linuxbrew/.linuxbrew/bin/ollama")
+	}
+	if home != "" && runtime.GOOS != "windows" {
+		paths = append(paths, filepath.Join(home, ".ollama", "bin", "ollama"))
+	}
+	return paths
+}
+
+// ollamaManualInstall is the advice shown when honeyrag won't or can't
+// install Ollama itself.
+func ollamaManualInstall() string {
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf("Install it with OllamaSetup.exe from %s (or winget install Ollama.Ollama) and retry, or re-run with --yes to let honeyrag download it", ollamaDownloadURL)
+	}
+	return fmt.Sprintf("Install it from %s and retry, or re-run with --yes to let honeyrag download it", ollamaDownloadURL)
+}
+
+// confirmOllamaInstall asks on the terminal, before the TUI takes it over,
+// whether Ollama may be downloaded, if it is going to be needed. It returns
+// false without asking when Ollama is already available.
+func (m Model) confirmOllamaInstall() bool {
+	if ollamaBinary() != "" || m.ollamaRemote() {
+		return false
+	}
+	if _, ok := ollamaArchive(); !ok || m.verifyService(context.Background(), "ollama") {
+		return false
+	}
+	if homebrew() != "" {
+		return confirm("Ollama is not installed. Install it with brew install ollama?")
+	}
+	_, binary, err := ollamaInstallDir()
+	if err != nil {
+		return false
+	}
+	return confirm(fmt.Sprintf("Ollama is not installed. Download the official release to %s?", filepath.Dir(binary)))
+}
+
+// homebrew returns the path of brew on macOS, where Ollama is installed with
+// it when it is there, or "".
+func homebrew() string {
+	if runtime.GOOS != "darwin" {
+		return ""
+	}
+	path, err := exec.LookPath("brew")
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// brewInstallOllama runs brew install ollama, streaming its output under
+// step index and into logs/ollama-install.log. It returns the path of the
+// installed binary.
+func (m Model) brewInstallOllama(ctx context.Context, index int, brew string) (string, error) {
+	logFile, err := m.openLog("ollama-install")
+	if err != nil {
+		return "", fmt.Errorf("failed to create log file: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.CommandContext(ctx, brew, "install", "ollama")
+	output := m.stepLogWriter(index, logFile)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("brew install ollama failed (%v). Last output:\n%s", err, readLastLines(logFile.path, 10))
+	}
+	if path := ollamaBinary(); path != "" {
+		return path, nil
+	}
+	return "", errors.New("brew install ollama succeeded but no ollama binary was found")
+}
+
+// installOllama downloads the official release archive for this platform,
+// checks it against the release's sha256sum.txt and unpacks it under
+// ollamaInstallDir. onProgress is called with the bytes downloaded so far.
+// It returns the path of the installed binary.
+func installOllama(ctx context.Context, onProgress func(completed, total int64)) (string, error) {
+	archive, ok := ollamaArchive()
+	if !ok {
+		return "", fmt.Errorf("no Ollama release for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	prefix, binary, err := ollamaInstallDir()
+	if err != nil {
+		return "", err
+	}
+
+	want, err := ollamaChecksum(ctx, archive)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(prefix, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(prefix, archive+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	resp, err := httpGet(ctx, ollamaReleaseURL+archive)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	progress := &progressWriter{total: resp.ContentLength, onProgress: onProgress}
+	if _, err := io.Copy(io.MultiWriter(tmp, hash, progress), resp.Body); err != nil {
+		return "", fmt.Errorf("downloading %s: %v", archive, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", archive, got, want)
+	}
+
+	if strings.HasSuffix(archive, ".zip") {
+		err = extractZip(tmp, progress.written, prefix)
+	} else if _, err = tmp.Seek(0, io.SeekStart); err == nil {
+		err = extractTarGz(tmp, prefix)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unpacking %s: %v", archive, err)
+	}
+	return binary, nil
+}
+
+// ollamaChecksum looks up archive's SHA256 in the release's sha256sum.txt,
+// whose lines read "<hex>  ./<archive>".
+func ollamaChecksum(ctx context.Context, archive string) (string, error) {
+	resp, err := httpGet(ctx, ollamaReleaseURL+"sha256sum.txt")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "./") == archive {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s in sha256sum.txt", archive)
+}
+
+// httpGet GETs url and fails unless it answers 200.
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// extractTarGz unpacks a release archive into prefix. Archives lay out
+// bin/ and lib/ like /usr; files at the top level (as in the macOS archive)
+// go in bin/ next to the binary.
+func extractTarGz(r io.Reader, prefix string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			continue
+		}
+		if !strings.ContainsRune(name, filepath.Separator) && hdr.Typeflag != tar.TypeDir {
+			name = filepath.Join("bin", name)
+		}
+		target := filepath.Join(prefix, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("unsafe link %q in archive", hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeFile replaces path with the contents of r. The old file is removed
+// first so a running binary isn't overwritten in place.
+// extractZip unpacks a Windows release archive of size bytes, which is laid
+// out like the install directory itself, into dir.
+func extractZip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		name, err := archivePath(file.Name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, name)
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, rc, 0755)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivePath cleans an archive entry name into a relative path, refusing
+// any that would land outside the directory being unpacked into.
+func archivePath(entry string) (string, error) {
+	name := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(entry, "./")))
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unsafe path %q in archive", entry)
+	}
+	return name, nil
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// progressWriter reports how many bytes have been written through it.
+type progressWriter struct {
+	written    int64
+	total      int64
+	onProgress func(completed, total int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	if w.total > 0 {
+		w.onProgress(w.written, w.total)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// jsonOutput is where the --json and --output=json streams are written.
+var jsonOutput io.Writer = os.Stdout
+
+// stepEvent is one line of the --json status stream.
+type stepEvent struct {
+	Step      string `json:"step"`
+	Status    string `json:"status"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+	Error     string `json:"error,omitempty"`
+	// Reused is set when the step found its service already running.
+	Reused bool `json:"reused,omitempty"`
+}
+
+// emitStep writes step index's current status to the --json stream, or
+// with --output=json its event. err is the step's failure, if it just
+// failed.
+func (m Model) emitStep(index int, err error) {
+	if !m.jsonStream {
+		return
+	}
+	if m.jsonEvents {
+		m.emitStepEvent(index, err)
+		return
+	}
+	step := m.steps[index]
+	event := stepEvent{Step: step.Name, Status: step.Status, Reused: step.Reused}
+	if !step.StartedAt.IsZero() && step.Status != "pending" && step.Status != "skipped" {
+		event.ElapsedMS = step.elapsed().Milliseconds()
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	json.NewEncoder(jsonOutput).Encode(event)
+}
+
+// emitPipeline writes the overall outcome to the --json stream once every
+// step is done or skipped.
+func (m Model) emitPipeline() {
+	if !m.jsonStream {
+		return
+	}
+	if m.jsonEvents {
+		m.emitReadyEvent()
+		return
+	}
+	json.NewEncoder(jsonOutput).Encode(stepEvent{
+		Step:      "pipeline",
+		Status:    "done",
+		ElapsedMS: m.finishedAt.Sub(m.startedAt).Round(time.Millisecond).Milliseconds(),
+	})
+}
+
+// failedStep returns the index of the first failed step, or -1.
+func (m Model) failedStep() int {
+	for i, step := range m.steps {
+		if step.Status == "error" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log modes selected with HONEYRAG_LOG_MODE.
+const (
+	// logModeAppend keeps one <service>.log and appends a session banner on
+	// every run.
+	logModeAppend = "append"
+	// logModeTimestamped writes <service>-YYYYMMDD-HHMMSS.log per run and
+	// points <service>.log at the latest one with a symlink.
+	logModeTimestamped = "timestamped"
+	// logModeTruncate overwrites <service>.log on every run.
+	logModeTruncate = "truncate"
+	// logModeRotate moves the last run's <service>.log to <service>.log.1,
+	// and older ones up to .log.N, before starting a fresh one.
+	logModeRotate = "rotate"
+)
+
+// serviceLog is a service's log file. Once it grows past maxSize it is moved
+// aside to <path>.1, older ones shifting up to <path>.<keep>, and a fresh file
+// is started, so a chatty service can use at most keep+1 times the cap.
+type serviceLog struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// openLog opens the log file for service according to the configured log
+// mode. The returned log's path is the file actually being written.
+func (m Model) openLog(service string) (*serviceLog, error) {
+	maxMB, err := strconv.Atoi(m.config["logMaxSize"])
+	if err != nil || maxMB <= 0 {
+		return nil, fmt.Errorf("invalid HONEYRAG_LOG_MAX_SIZE=%q: expected a positive number of megabytes", m.config["logMaxSize"])
+	}
+
+	keep, err := strconv.Atoi(m.config["logKeep"])
+	if err != nil || keep < 0 {
+		return nil, fmt.Errorf("invalid HONEYRAG_LOG_KEEP=%q: expected a number of old logs to keep", m.config["logKeep"])
+	}
+
+	latest := filepath.Join(m.logsDir, service+".log")
+	now := time.Now()
+
+	l := &serviceLog{path: latest, maxSize: int64(maxMB) << 20, keep: keep}
+	switch m.config["logMode"] {
+	case logModeAppend:
+		l.file, err = os.OpenFile(latest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			fmt.Fprintf(l.file, "\n==== session started %s ====\n", now.Format("2006-01-02 15:04:05"))
+		}
+	case logModeTimestamped:
+		l.path = filepath.Join(m.logsDir, fmt.Sprintf("%s-%s.log", service, now.Format("20060102-150405")))
+		l.file, err = os.Create(l.path)
+		if err == nil {
+			linkLatest(latest, l.path)
+			pruneTimestamped(m.logsDir, service, keep)
+		}
+	case logModeTruncate:
+		l.file, err = os.Create(latest)
+	case logModeRotate:
+		if info, err := os.Stat(latest); err == nil && info.Size() > 0 {
+			shiftGenerations(latest, keep)
+		}
+		l.file, err = os.Create(latest)
+	default:
+		return nil, fmt.Errorf("invalid HONEYRAG_LOG_MODE=%q: expected append, timestamped, truncate or rotate", m.config["logMode"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := l.file.Stat(); err == nil {
+		l.size = info.Size()
+	}
+	return l, nil
+}
+
+// shiftGenerations moves path to path.1, path.1 to path.2 and so on,
+// dropping whatever would go past path.<keep>. With keep 0, path is simply
+// removed.
+func shiftGenerations(path string, keep int) {
+	os.Remove(fmt.Sprintf("%s.%d", path, max(keep, 1)))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	if keep == 0 {
+		os.Remove(path)
+		return
+	}
+	os.Rename(path, path+".1")
+}
+
+// pruneTimestamped deletes all but the newest keep+1 timestamped logs of
+// service: this run's and keep earlier ones. The timestamps in the names sort
+// by time.
+func pruneTimestamped(logsDir, service string, keep int) {
+	runs, _ := filepath.Glob(filepath.Join(logsDir, service+"-[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]-[0-9][0-9][0-9][0-9][0-9][0-9].log"))
+	sort.Strings(runs)
+	for _, old := range runs[:max(len(runs)-keep-1, 0)] {
+		os.Remove(old)
+	}
+}
+
+// linkLatest points the <service>.log symlink at target. A regular file left
+// over from another log mode is renamed into the timestamped series rather
+// than deleted.
+func linkLatest(link, target string) {
+	if info, err := os.Lstat(link); err == nil {
+		if info.Mode()&os.ModeSymlink != 0 {
+			os.Remove(link)
+		} else {
+			ext := filepath.Ext(link)
+			stamped := fmt.Sprintf("%s-%s%s", link[:len(link)-len(ext)], info.ModTime().Format("20060102-150405"), ext)
+			os.Rename(link, stamped)
+		}
+	}
+	// Symlinks may be unavailable (e.g. on Windows without developer mode);
+	// the timestamped file is still written either way.
+	os.Symlink(filepath.Base(target), link)
+}
+
+func (l *serviceLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size+int64(len(p)) > l.maxSize && l.size > 0 {
+		l.rotate()
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// writeEnv records env, the environment a service is started with, sorted
+// and with secrets hidden, ahead of its output.
+func (l *serviceLog) writeEnv(env []string) {
+	env = append([]string(nil), env...)
+	sort.Strings(env)
+	var b strings.Builder
+	b.WriteString("---- environment ----\n")
+	for _, kv := range env {
+		b.WriteString(redactEnv(kv) + "\n")
+	}
+	b.WriteString("---------------------\n")
+	l.Write([]byte(b.String()))
+}
+
+// redactEnv hides the value of a NAME=value pair if the name suggests a
+// secret: an API key, token, password or the like.
+func redactEnv(kv string) string {
+	name, _, _ := strings.Cut(kv, "=")
+	upper := strings.ToUpper(name)
+	for _, word := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL"} {
+		if strings.Contains(upper, word) {
+			return name + "=****"
+		}
+	}
+	return kv
+}
+
+// Close closes the file. Logs of services stay open for as long as the
+// service runs; this is for steps that write their own output, such as uv
+// sync.
+func (l *serviceLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+func (l *serviceLog) rotate() {
+	l.file.Close()
+	shiftGenerations(l.path, l.keep)
+
+	file, err := os.Create(l.path)
+	if err != nil {
+		// Discard output rather than failing the child's writes.
+		file, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	}
+	l.file = file
+	l.size = 0
+	previous := "discarded"
+	if l.keep > 0 {
+		previous = "in " + filepath.Base(l.path) + ".1"
+	}
+	fmt.Fprintf(l.file, "==== log rotated %s (previous output %s) ====\n",
+		time.Now().Format("2006-01-02 15:04:05"), previous)
+}
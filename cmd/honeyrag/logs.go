@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// logPollInterval is how often `honeyrag logs -f` checks for new output.
+const logPollInterval = 250 * time.Millisecond
+
+// logPrefixColors tells services apart when several logs are interleaved,
+// in the order of services.
+var logPrefixColors = []lipgloss.Color{"#FFD700", "#00BFFF", "#DDA0DD", "#00FF00"}
+
+// runLogs implements `honeyrag logs [service...] [-f] [--lines N]`: it prints
+// the tail of each service's log under logsDir and, with -f, keeps printing
+// new lines as they are written. With no service named, every log is shown
+// with a colored service prefix on each line. It returns the process exit
+// code.
+func runLogs(logsDir string, args []string) int {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "keep printing new lines as they are written")
+	fs.BoolVar(follow, "follow", false, "alias for -f")
+	lines := fs.Int("lines", 20, "number of lines to show from the end of each log")
+	fs.IntVar(lines, "n", 20, "alias for --lines")
+
+	// Flags may come before or after the service names.
+	var names []string
+	fs.Parse(args)
+	for fs.NArg() > 0 {
+		names = append(names, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+
+	var selected []service
+	for _, name := range names {
+		svc, ok := lookupService(name)
+		if !ok {
+			fmt.Printf("Error: unknown service %q (known: %s)\n", name, serviceNames())
+			return 2
+		}
+		selected = append(selected, svc)
+	}
+	prefixed := len(selected) != 1
+	if len(selected) == 0 {
+		selected = services
+	}
+
+	followers := make([]*logFollower, len(selected))
+	for i, svc := range selected {
+		f := &logFollower{path: filepath.Join(logsDir, svc.name+".log")}
+		if prefixed {
+			f.prefix = fmt.Sprintf("%-9s", svc.name) + "| "
+			if !styles.plain {
+				f.prefix = lipgloss.NewStyle().Foreground(logPrefixColors[serviceIndex(svc)%len(logPrefixColors)]).
+					Render(fmt.Sprintf("%-9s", svc.name)) + "| "
+			}
+		}
+		followers[i] = f
+
+		if _, err := os.Stat(f.path); err != nil {
+			if !*follow {
+				fmt.Printf("%s%s has no log yet (%s)\n", f.prefix, svc.label, f.path)
+			}
+			continue
+		}
+		if tail := readLastLines(f.path, *lines); tail != "" {
+			for _, line := range strings.Split(tail, "\n") {
+				fmt.Println(f.prefix + line)
+			}
+		}
+		f.open(true)
+	}
+	if !*follow {
+		return 0
+	}
+
+	for {
+		for _, f := range followers {
+			for _, line := range f.poll() {
+				fmt.Println(f.prefix + line)
+			}
+		}
+		time.Sleep(logPollInterval)
+	}
+}
+
+// serviceIndex returns svc's position in services.
+func serviceIndex(svc service) int {
+	for i, s := range services {
+		if s.name == svc.name {
+			return i
+		}
+	}
+	return 0
+}
+
+// logFollower reads the lines appended to a log file. It notices when the
+// file is replaced, by rotation or by a new run in timestamped mode moving
+// the <service>.log link, and carries on with the new file from its start.
+type logFollower struct {
+	path   string
+	prefix string
+
+	file    *os.File
+	partial []byte
+}
+
+// open opens path, positioned at its end if atEnd, so only output written
+// from now on is read.
+func (f *logFollower) open(atEnd bool) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return
+	}
+	if atEnd {
+		file.Seek(0, io.SeekEnd)
+	}
+	f.file = file
+}
+
+// poll returns the complete lines written since the last call. A line still
+// being written is held back until its newline arrives.
+func (f *logFollower) poll() []string {
+	var lines []string
+	if f.file == nil {
+		f.open(false)
+		if f.file == nil {
+			return nil
+		}
+	}
+
+	lines = append(lines, f.read()...)
+
+	current, err := f.file.Stat()
+	if err != nil {
+		return lines
+	}
+	info, err := os.Stat(f.path)
+	switch {
+	case err != nil:
+		// Mid-rotation; look again next time.
+	case !os.SameFile(info, current):
+		f.file.Close()
+		f.file = nil
+		if len(f.partial) > 0 {
+			lines = append(lines, string(f.partial))
+			f.partial = nil
+		}
+		f.open(false)
+		if f.file != nil {
+			lines = append(lines, f.read()...)
+		}
+	default:
+		if pos, err := f.file.Seek(0, io.SeekCurrent); err == nil && current.Size() < pos {
+			// Truncated in place (HONEYRAG_LOG_MODE=truncate).
+			f.file.Seek(0, io.SeekStart)
+			f.partial = nil
+		}
+	}
+	return lines
+}
+
+// read reads f.file to its end and splits off the complete lines.
+func (f *logFollower) read() []string {
+	data, err := io.ReadAll(f.file)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	f.partial = append(f.partial, data...)
+
+	var lines []string
+	for {
+		i := bytes.IndexByte(f.partial, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(string(f.partial[:i]), "\r"))
+		f.partial = f.partial[i+1:]
+	}
+	return lines
+}
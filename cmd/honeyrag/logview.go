@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// logViewMaxLines bounds how much history the log pane keeps in memory.
+const logViewMaxLines = 5000
+
+// logViewTickMsg asks the log pane to poll its log file. gen ties it to the
+// pane it was scheduled for, so reopening the pane doesn't leave two polling
+// loops running.
+type logViewTickMsg struct{ gen int }
+
+// activeLogStep picks the step the log pane should show: the first running
+// step, otherwise the first failed one, otherwise the last service started.
+// It returns -1 if there is none.
+func (m Model) activeLogStep() int {
+	for _, status := range []string{"running", "error"} {
+		for i, step := range m.steps {
+			if step.Status == status {
+				return i
+			}
+		}
+	}
+	for i := len(m.steps) - 1; i >= 0; i-- {
+		if m.steps[i].Status == "done" && m.steps[i].LogFile != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// openLogView shows the full-screen log pane for step index. A step with a
+// log file is seeded from the file's tail and then follows it, so the pane
+// keeps up with a service long after its step has finished; anything else
+// shows the lines captured so far.
+func (m *Model) openLogView(index int) tea.Cmd {
+	step := m.steps[index]
+
+	var lines []string
+	m.logViewFollow = nil
+	if step.LogFile != "" {
+		path := filepath.Join(m.logsDir, step.LogFile)
+		if tail := readLastLines(path, logViewMaxLines); tail != "" {
+			lines = strings.Split(tail, "\n")
+		}
+		m.logViewFollow = &logFollower{path: path}
+		m.logViewFollow.open(true)
+	} else {
+		lines = append(lines, step.LogLines...)
+	}
+
+	m.logViewOpen = true
+	m.logViewStep = index
+	m.logViewLines = lines
+	m.logViewGen++
+	m.logView = viewport.New(m.width, m.logViewHeight())
+	m.findMatches()
+	m.refreshLogView()
+	m.logView.GotoBottom()
+	return m.logViewTick()
+}
+
+func (m *Model) closeLogView() {
+	m.logViewOpen = false
+	m.logViewSearching = false
+	if m.logViewFollow != nil && m.logViewFollow.file != nil {
+		m.logViewFollow.file.Close()
+	}
+	m.logViewFollow = nil
+}
+
+func (m Model) logViewTick() tea.Cmd {
+	if m.logViewFollow == nil {
+		return nil
+	}
+	gen := m.logViewGen
+	return tea.Tick(logPollInterval, func(time.Time) tea.Msg { return logViewTickMsg{gen} })
+}
+
+// pollLogView appends whatever has been written to the followed log file
+// since the last poll.
+func (m Model) pollLogView(msg logViewTickMsg) (Model, tea.Cmd) {
+	if !m.logViewOpen || msg.gen != m.logViewGen || m.logViewFollow == nil {
+		return m, nil
+	}
+	lines := m.logViewFollow.poll()
+	if len(lines) > 0 {
+		m.appendLogView(lines...)
+	}
+	return m, m.logViewTick()
+}
+
+// nextLogStep returns the step after (or, with step -1, before) index that
+// has output to show, wrapping around, or index itself if there is none.
+func (m Model) nextLogStep(index, step int) int {
+	n := len(m.steps)
+	for i := 1; i < n; i++ {
+		j := ((index+step*i)%n + n) % n
+		if m.steps[j].LogFile != "" || len(m.steps[j].LogLines) > 0 {
+			return j
+		}
+	}
+	return index
+}
+
+// appendLogView adds freshly written lines, following the tail if the user
+// hasn't scrolled away from the bottom.
+func (m *Model) appendLogView(lines ...string) {
+	follow := m.logView.AtBottom()
+	m.logViewLines = append(m.logViewLines, lines...)
+	if len(m.logViewLines) > logViewMaxLines {
+		m.logViewLines = m.logViewLines[len(m.logViewLines)-logViewMaxLines:]
+	}
+	m.findMatches()
+	m.refreshLogView()
+	if follow {
+		m.logView.GotoBottom()
+	}
+}
+
+// replaceLogViewLine overwrites the last line for a progress redraw.
+func (m *Model) replaceLogViewLine(line string) {
+	if len(m.logViewLines) == 0 {
+		m.appendLogView(line)
+		return
+	}
+	m.logViewLines[len(m.logViewLines)-1] = line
+	m.findMatches()
+	m.refreshLogView()
+}
+
+func (m *Model) refreshLogView() {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+	lines := make([]string, len(m.logViewLines))
+	for i, line := range m.logViewLines {
+		lines[i] = highlight(truncate(line, width-3), m.logViewQuery)
+	}
+	m.logView.SetContent(strings.Join(lines, "\n"))
+}
+
+// highlight marks every case-insensitive occurrence of query in line.
+func highlight(line, query string) string {
+	if query == "" {
+		return line
+	}
+	lower, q := strings.ToLower(line), strings.ToLower(query)
+	if len(lower) != len(line) {
+		// Lowering changed byte offsets; better unmarked than mangled.
+		return line
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, q)
+		if i < 0 {
+			b.WriteString(line)
+			return b.String()
+		}
+		b.WriteString(line[:i])
+		b.WriteString(styles.match.Render(line[i : i+len(q)]))
+		line, lower = line[i+len(q):], lower[i+len(q):]
+	}
+}
+
+// findMatches records which lines contain the search query.
+func (m *Model) findMatches() {
+	m.logViewMatches = m.logViewMatches[:0]
+	if m.logViewQuery == "" {
+		return
+	}
+	q := strings.ToLower(m.logViewQuery)
+	for i, line := range m.logViewLines {
+		if strings.Contains(strings.ToLower(line), q) {
+			m.logViewMatches = append(m.logViewMatches, i)
+		}
+	}
+}
+
+// jumpToMatch scrolls to the next match after the top of the pane, or with
+// step -1 the previous one before it, wrapping around at either end.
+func (m *Model) jumpToMatch(step int) {
+	if len(m.logViewMatches) == 0 {
+		if m.logViewQuery != "" {
+			m.notice = fmt.Sprintf("no match for %q", m.logViewQuery)
+		}
+		return
+	}
+	top := m.logView.YOffset
+	target := -1
+	if step > 0 {
+		for _, line := range m.logViewMatches {
+			if line > top {
+				target = line
+				break
+			}
+		}
+		if target < 0 {
+			target = m.logViewMatches[0]
+		}
+	} else {
+		for i := len(m.logViewMatches) - 1; i >= 0; i-- {
+			if m.logViewMatches[i] < top {
+				target = m.logViewMatches[i]
+				break
+			}
+		}
+		if target < 0 {
+			target = m.logViewMatches[len(m.logViewMatches)-1]
+		}
+	}
+	m.logView.SetYOffset(target)
+}
+
+func (m *Model) resizeLogView() {
+	m.logView.Width = m.width
+	m.logView.Height = m.logViewHeight()
+	m.refreshLogView()
+}
+
+// logViewHeight leaves room for the pane's header and footer.
+func (m Model) logViewHeight() int {
+	if m.height <= 0 {
+		return 20
+	}
+	return max(m.height-4, 1)
+}
+
+func (m Model) updateLogView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.logViewSearching {
+		return m.updateLogSearch(msg), nil
+	}
+	switch msg.String() {
+	case "esc", "l":
+		m.closeLogView()
+		return m, nil
+	case "o":
+		return m, m.openPager(m.logViewStep)
+	case "g", "home":
+		m.logView.GotoTop()
+		return m, nil
+	case "G", "end":
+		m.logView.GotoBottom()
+		return m, nil
+	case "/":
+		m.logViewSearching = true
+		m.logViewQuery = ""
+		m.findMatches()
+		m.refreshLogView()
+		return m, nil
+	case "n":
+		m.jumpToMatch(1)
+		return m, nil
+	case "N":
+		m.jumpToMatch(-1)
+		return m, nil
+	case "tab", "shift+tab":
+		step := 1
+		if msg.String() == "shift+tab" {
+			step = -1
+		}
+		if next := m.nextLogStep(m.logViewStep, step); next != m.logViewStep {
+			query := m.logViewQuery
+			m.closeLogView()
+			m.logViewQuery = query
+			return m, m.openLogView(next)
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.logView, cmd = m.logView.Update(msg)
+	return m, cmd
+}
+
+// updateLogSearch edits the search query typed after '/'. Matches are
+// highlighted as the query is typed; enter jumps to the first one below the
+// top of the pane and esc drops the search.
+func (m Model) updateLogSearch(msg tea.KeyMsg) Model {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.logViewSearching = false
+		m.jumpToMatch(1)
+		return m
+	case tea.KeyEsc:
+		m.logViewSearching = false
+		m.logViewQuery = ""
+	case tea.KeyBackspace:
+		if q := []rune(m.logViewQuery); len(q) > 0 {
+			m.logViewQuery = string(q[:len(q)-1])
+		}
+	case tea.KeySpace:
+		m.logViewQuery += " "
+	case tea.KeyRunes:
+		m.logViewQuery += string(msg.Runes)
+	default:
+		return m
+	}
+	m.findMatches()
+	m.refreshLogView()
+	return m
+}
+
+func (m Model) logPaneView() string {
+	var b strings.Builder
+
+	step := m.steps[m.logViewStep]
+	source := "captured output"
+	if step.LogFile != "" {
+		source = filepath.Join(m.logsDir, step.LogFile)
+	}
+	b.WriteString(styles.title.Render(step.Name + glyphs.dash + source))
+	b.WriteString("\n")
+	b.WriteString(m.logView.View())
+	b.WriteString("\n")
+	switch {
+	case m.logViewSearching:
+		b.WriteString(fmt.Sprintf("  /%s%s %s", m.logViewQuery, glyphs.caret, styles.dim.Render(fmt.Sprintf("(%d matches) enter find | esc cancel", len(m.logViewMatches)))))
+	case m.logViewQuery != "":
+		b.WriteString(styles.dim.Render(fmt.Sprintf("  %3.0f%% | %q: %d matches | n/N next/prev | / search | tab next log | esc back", m.logView.ScrollPercent()*100, m.logViewQuery, len(m.logViewMatches))))
+	default:
+		b.WriteString(styles.dim.Render(fmt.Sprintf("  %3.0f%% | %s PgUp/PgDn g/G scroll | / search | tab next log | o pager | esc back", m.logView.ScrollPercent()*100, glyphs.updown)))
+	}
+	b.WriteString("\n")
+	if m.notice != "" {
+		b.WriteString(styles.error.Render("  " + m.notice))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
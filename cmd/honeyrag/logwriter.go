@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxPendingLine caps how much of an unterminated line is buffered before it
+// is forwarded anyway.
+const maxPendingLine = 64 * 1024
+
+// notifier forwards messages from background goroutines to the running
+// program. Until attach is called (and always in headless mode) messages are
+// dropped.
+type notifier struct {
+	mu   sync.Mutex
+	send func(tea.Msg)
+}
+
+func (n *notifier) attach(send func(tea.Msg)) {
+	n.mu.Lock()
+	n.send = send
+	n.mu.Unlock()
+}
+
+func (n *notifier) notify(msg tea.Msg) {
+	n.mu.Lock()
+	send := n.send
+	n.mu.Unlock()
+	if send != nil {
+		send(msg)
+	}
+}
+
+// lineWriter copies a child's output into its log file and hands every
+// complete line to onLine. Lines ended by a bare '\r' (progress bars such as
+// the Hugging Face download meter) are passed with redraw set.
+type lineWriter struct {
+	mu      sync.Mutex
+	file    io.Writer
+	onLine  func(line string, redraw bool)
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(p); err != nil {
+		return 0, err
+	}
+
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexAny(w.pending, "\r\n")
+		if i < 0 {
+			break
+		}
+		if w.pending[i] == '\n' {
+			w.emit(w.pending[:i], false)
+			w.pending = w.pending[i+1:]
+			continue
+		}
+		// A '\r' at the end of the buffer may be the first half of "\r\n".
+		if i == len(w.pending)-1 {
+			break
+		}
+		if w.pending[i+1] == '\n' {
+			w.emit(w.pending[:i], false)
+			w.pending = w.pending[i+2:]
+			continue
+		}
+		w.emit(w.pending[:i], true)
+		w.pending = w.pending[i+1:]
+	}
+	if len(w.pending) > maxPendingLine {
+		w.emit(w.pending, false)
+		w.pending = nil
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) emit(line []byte, redraw bool) {
+	text := string(line)
+	if strings.TrimSpace(text) != "" {
+		w.onLine(text, redraw)
+	}
+}
+
+// stepLogWriter returns a writer for a service's output that writes to file
+// and streams each line into step index's LogLines.
+func (m Model) stepLogWriter(index int, file io.Writer) io.Writer {
+	return &lineWriter{
+		file: file,
+		onLine: func(line string, redraw bool) {
+			m.notifier.notify(logUpdateMsg{index: index, line: line, redraw: redraw})
+		},
+	}
+}
@@ -1,53 +1,43 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/joho/godotenv"
 )
 
-var (
-	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#FFB347")).
-			MarginBottom(1)
-
-	honeyStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFD700"))
-
-	successStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#00FF00"))
-
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF6B6B"))
-
-	waitingStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#87CEEB"))
-
-	dimStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#666666"))
-
-	urlStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#00BFFF")).
-			Underline(true)
-
-	logStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#888888"))
-
-	configStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#DDA0DD"))
+// Step indexes, in pipeline order, as buildSteps lays them out before any
+// are dropped; after that, find steps by Key.
+const (
+	stepTools = iota
+	stepPorts
+	stepPythonDeps
+	stepOllamaInstall
+	stepOllamaServer
+	stepEmbedding
+	stepVLLM
+	stepLightRAG
+	stepAgent
 )
 
 type Step struct {
@@ -55,36 +45,196 @@ type Step struct {
 	Status      string
 	Description string
 	LogLines    []string
-	Info        string
+	// Info says what a running step is doing right now, when its output
+	// tells, e.g. "downloading model files 45%", and takes the place of
+	// Hint. Whatever it last said stays under the step once it is done.
+	Info    string
+	LogFile string
+	// DependsOn names, by Key, the steps that must be done or skipped
+	// before this one starts.
+	DependsOn []string
+	// Service is the managed service this step starts, if any; such steps
+	// can be restarted from the TUI. External is set when the service is
+	// expected to be running already (--skip) and the step only checks it.
+	Service  string
+	External bool
+	// Key names the step in HONEYRAG_SKIP_STEPS, e.g. "vllm".
+	Key string
+	// Run does the step's work. Hint is shown under the step while it runs
+	// without output yet, and Extra, if set, renders more lines under it
+	// once it has started.
+	Run   func(m Model, ctx context.Context, index int) error
+	Hint  string
+	Extra func(m Model) string
+	// Deadline is when the running step's health wait gives up.
+	Deadline time.Time
+	// Completed and Total track download progress in bytes, when known.
+	Completed int64
+	Total     int64
+	// StartedAt and FinishedAt time the step's last run; FinishedAt is zero
+	// while it is still running.
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Reused is set when the step found its service already running and
+	// used it rather than starting one.
+	Reused bool
+	// DownSince is when the service stopped answering health checks after
+	// the step had finished, if it has since the step last ran.
+	DownSince time.Time
+
+	// redrawing is set while the last log line is a progress bar that the
+	// next redraw should overwrite.
+	redrawing bool
 }
 
 type Model struct {
-	steps       []Step
-	spinner     spinner.Model
-	currentStep int
-	done        bool
-	err         error
-	baseDir     string
-	logsDir     string
-	quitting    bool
-	ports       map[string]string
-	config      map[string]string
-	logMutex    sync.Mutex
-	processes   []*exec.Cmd
+	steps    []Step
+	spinner  spinner.Model
+	progress progress.Model
+	done     bool
+	err      error
+	baseDir  string
+	logsDir  string
+	quitting bool
+	ports    map[string]string
+	config   map[string]string
+	timeouts map[string]time.Duration
+	probe    probeSettings
+	width    int
+	height   int
+
+	consent consent
+
+	// sources says where each setting came from, for config show.
+	sources configSources
+
+	// verbose keeps up to verboseLogLines log lines under running and
+	// failed steps, wrapped rather than cut to the terminal width
+	// (--verbose).
+	verbose bool
+
+	// dryRun prints the commands the steps would run instead of running
+	// them (--dry-run).
+	dryRun bool
+
+	// debugEnv writes the environment each service is started with, secrets
+	// hidden, at the top of its log (--debug-env).
+	debugEnv bool
+
+	// expose makes the agent, LightRAG and vLLM listen on every interface
+	// instead of loopback (--expose).
+	expose bool
+
+	// forceRestart stops services left running by an earlier honeyrag and
+	// starts them afresh instead of adopting them (--force-restart).
+	forceRestart bool
+
+	// runTimeout, if set, is how long the whole stack may take to come up
+	// before the run is abandoned (--timeout).
+	runTimeout time.Duration
+
+	// monitoring is set once the health poll started after the pipeline
+	// first finished; healthFailures counts consecutive failed checks per
+	// step.
+	monitoring     bool
+	healthFailures map[int]int
+
+	// supervision configures the health poll; restarts counts automatic
+	// restarts per step.
+	supervision supervision
+	restarts    map[int]int
+
+	// usage is each service's memory, CPU and GPU use, sampled every
+	// usageInterval once the stack is up; usageSamples are the CPU times
+	// the last sample was taken from.
+	usage        map[string]serviceUsage
+	usageSamples map[int]cpuSample
+
+	// jsonStream replaces the TUI with one JSON object per step transition
+	// on stdout (--json); with jsonEvents set, one per event, log lines
+	// included (--output=json, see events.go).
+	jsonStream bool
+	jsonEvents bool
+
+	// startedAt and finishedAt time the whole pipeline, from the first
+	// dispatched step until every step is done or skipped.
+	startedAt  time.Time
+	finishedAt time.Time
+
+	// prior is the saved known-good state when it differs from the current
+	// config. The pipeline waits until the user accepts or dismisses it.
+	prior *savedState
+
+	// notice is a one-off message for the user, such as a pager that
+	// failed to open, shown until the next key press.
+	notice string
+
+	// confirmQuit is set when 'q' is pressed with the stack up: the next
+	// key says whether to stop it.
+	confirmQuit bool
+
+	// keyPrefix is a pressed 'R' (restart) or 'c' (copy) waiting for the
+	// number that says which service or endpoint it applies to.
+	keyPrefix string
+
+	// Full-screen log pane, toggled with 'l'. logViewFollow reads what the
+	// step's log file gains while the pane is open; logViewQuery is the '/'
+	// search, with logViewMatches the lines containing it.
+	logView          viewport.Model
+	logViewOpen      bool
+	logViewStep      int
+	logViewLines     []string
+	logViewFollow    *logFollower
+	logViewGen       int
+	logViewSearching bool
+	logViewQuery     string
+	logViewMatches   []int
+
+	// errorHints explain known failures; errHint is the one matching err.
+	errorHints []errorHint
+	errHint    *errorHint
+
+	// selected is the step under the cursor moved with the arrow keys, or
+	// -1 before the first press; inspecting shows its details.
+	selected   int
+	inspecting bool
+
+	*runtimeState
 }
 
 type stepDoneMsg struct{ index int }
 type stepErrorMsg struct {
 	index int
 	err   error
+	// cancelled is set when the step's context was cancelled by the time
+	// it returned, because another step failed or the user quit.
+	cancelled bool
 }
 type logUpdateMsg struct {
 	index int
 	line  string
+	// redraw is set for lines ended by a bare carriage return, i.e. progress
+	// bars that redraw themselves in place.
+	redraw bool
+	// info, if set, replaces the step's Info: what it is doing right now.
+	info string
+	// reused marks the step as having found its service already up instead
+	// of starting it.
+	reused bool
 }
 type configLoadedMsg struct {
 	config map[string]string
 }
+type servicesStoppedMsg struct{}
+type stepProgressMsg struct {
+	index            int
+	completed, total int64
+}
+type stepDeadlineMsg struct {
+	index    int
+	deadline time.Time
+}
+type pipelineStartMsg struct{}
 
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -93,348 +243,1492 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func initialModel(baseDir string) Model {
+// initialModel builds the model from the environment, configs/honeyrag.yaml
+// and configs/.env.
+// portOverrides maps Model.ports keys to ports given on the command line,
+// which take precedence over the *_PORT variables.
+func initialModel(baseDir string, portOverrides map[string]string) (Model, error) {
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700"))
+	s.Spinner = glyphs.spinner
+	s.Style = styles.spinner
+	bar := newProgressBar()
 
 	logsDir := filepath.Join(baseDir, "logs")
 	os.MkdirAll(logsDir, 0755)
 
+	// The real environment wins over configs/honeyrag.yaml, which wins over
+	// configs/.env.
+	env := environSet()
+	settings, err := loadFileConfig(filepath.Join(baseDir, "configs", configFileName))
+	if err != nil {
+		return Model{}, err
+	}
+	sources := configSources{env: env, file: applyFileConfig(settings, env), flags: make(map[string]string)}
 	envPath := filepath.Join(baseDir, "configs", ".env")
+	sources.dotenv, _ = godotenv.Read(envPath)
 	godotenv.Load(envPath)
+	errorHints, err := loadErrorHints(baseDir)
+	if err != nil {
+		return Model{}, err
+	}
 
-	ports := map[string]string{
-		"ollama":   getEnv("OLLAMA_PORT", "11434"),
-		"vllm":     getEnv("VLLM_PORT", "8000"),
-		"lightrag": getEnv("LIGHTRAG_PORT", "9621"),
-		"agno":     getEnv("AGNO_PORT", "8081"),
+	ports := make(map[string]string)
+	for _, svc := range services {
+		port, ok := portOverrides[svc.portKey]
+		if ok {
+			sources.flags[svc.portEnv] = "--" + svc.name + "-port"
+		} else {
+			port = getEnv(svc.portEnv, svc.defaultPort)
+		}
+		ports[svc.portKey] = port
 	}
 
 	config := map[string]string{
 		"model":   getEnv("VLLM_MODEL", "Qwen/Qwen2.5-1.5B-Instruct"),
 		"gpuUtil": getEnv("VLLM_GPU_MEMORY_UTILIZATION", "0.8"),
 		"maxLen":  getEnv("VLLM_MAX_MODEL_LEN", "2048"),
+		"device":  getEnv("VLLM_DEVICE", ""),
+
+		// VLLM_TENSOR_PARALLEL_SIZE is accepted as a spelling of
+		// VLLM_TENSOR_PARALLEL, after vLLM's --tensor-parallel-size.
+		"tensorParallel": getEnv("VLLM_TENSOR_PARALLEL", getEnv("VLLM_TENSOR_PARALLEL_SIZE", "")),
+		"quantization":   getEnv("VLLM_QUANTIZATION", ""),
+		"vllmAPIKey":     getEnv("VLLM_API_KEY", ""),
+		"vllmExtraArgs":  getEnv("VLLM_EXTRA_ARGS", ""),
+
+		// OLLAMA_EMBEDDING_MODEL is accepted as a spelling of
+		// OLLAMA_EMBED_MODEL; without either, pull what LightRAG embeds with.
+		"embedModel": getEnv("OLLAMA_EMBED_MODEL", getEnv("OLLAMA_EMBEDDING_MODEL", getEnv("EMBEDDING_MODEL", "nomic-embed-text"))),
+		"ollamaHost": getEnv("OLLAMA_HOST", ""),
+
+		// Where LightRAG gets embeddings: Ollama, or with vllm an
+		// OpenAI-compatible API, EMBEDDING_BINDING_HOST or else the vLLM
+		// honeyrag starts, in which case nothing needs Ollama.
+		"embeddingBackend": getEnv("EMBEDDING_BACKEND", embeddingBackendOllama),
+		"embedHost":        getEnv("EMBEDDING_BINDING_HOST", ""),
+
+		// The address the agent listens on; see bindHost.
+		"agentBind": getEnv("AGNO_HOST", "127.0.0.1"),
+
+		// Services already running on another machine; see serviceEndpoint.
+		"vllmHost":     getEnv("VLLM_HOST", ""),
+		"lightragHost": getEnv("LIGHTRAG_HOST", ""),
+
+		"llmBackend":  getEnv("HONEYRAG_LLM_BACKEND", llmBackendVLLM),
+		"ollamaModel": getEnv("OLLAMA_LLM_MODEL", defaultOllamaLLM()),
+
+		// An OpenAI-compatible endpoint to use instead of vLLM; setting
+		// LLM_BINDING_HOST alone selects the remote backend (see
+		// defaultLLMBackend).
+		"llmHost":   getEnv("LLM_BINDING_HOST", ""),
+		"llmAPIKey": getEnv("LLM_API_KEY", getEnv("LLM_BINDING_API_KEY", "")),
+		"llmModel":  getEnv("LLM_MODEL", getEnv("VLLM_MODEL", "Qwen/Qwen2.5-1.5B-Instruct")),
+
+		"logMode":    getEnv("HONEYRAG_LOG_MODE", logModeAppend),
+		"logMaxSize": getEnv("HONEYRAG_LOG_MAX_SIZE", "100"),
+		"logKeep":    getEnv("HONEYRAG_LOG_KEEP", "3"),
+
+		// Free space, in GB, below which the Tools step stops; see
+		// checkDiskSpace.
+		"minFreeGB": getEnv("HONEYRAG_MIN_FREE_GB", "5"),
+
+		// Polled once LightRAG's /health answers, until it is ready; empty
+		// to skip. See waitForReady.
+		"lightragReadyPath": getEnv("LIGHTRAG_READY_PATH", "/documents/pipeline_status"),
+
+		// Move a service whose port something else holds to a free one; see
+		// checkPorts.
+		"autoPort": getEnv("HONEYRAG_AUTO_PORT", "false"),
+
+		// A folder of documents to upload to LightRAG once it is up; see
+		// ingestDocs.
+		"docsDir":    getEnv("HONEYRAG_DOCS_DIR", ""),
+		"docsIgnore": getEnv("HONEYRAG_DOCS_IGNORE", ""),
+	}
+
+	if config["device"] == "" {
+		config["device"] = detectDevice()
+		config["deviceDetected"] = "true"
+	}
+	if _, ok := os.LookupEnv("HONEYRAG_LLM_BACKEND"); !ok {
+		config["llmBackend"] = defaultLLMBackend(config)
+	}
+	if config["embeddingBackend"] == embeddingBackendVLLM {
+		// Nothing is pulled, so OLLAMA_EMBED_MODEL doesn't apply; the vLLM
+		// honeyrag starts embeds with the model it serves unless told
+		// otherwise.
+		config["embedModel"] = getEnv("EMBEDDING_MODEL", "")
+		if config["embedModel"] == "" && config["embedHost"] == "" {
+			config["embedModel"] = config["model"]
+		}
+	}
+
+	timeouts, err := loadStartupTimeouts()
+	if err != nil {
+		return Model{}, err
+	}
+	supervision, err := loadSupervision()
+	if err != nil {
+		return Model{}, err
+	}
+	probe, err := loadProbeSettings()
+	if err != nil {
+		return Model{}, err
+	}
+
+	steps := buildSteps(config)
+	if err := skipSteps(steps, getEnv("HONEYRAG_SKIP_STEPS", "")); err != nil {
+		return Model{}, err
+	}
+
+	m := Model{
+		steps:    steps,
+		spinner:  s,
+		progress: bar,
+		baseDir:  baseDir,
+		logsDir:  logsDir,
+		ports:    ports,
+		config:   config,
+		timeouts: timeouts,
+
+		healthFailures: make(map[int]int),
+		supervision:    supervision,
+		probe:          probe,
+		restarts:       make(map[int]int),
+		sources:        sources,
+		errorHints:     errorHints,
+		selected:       -1,
+
+		runtimeState: newRuntimeState(logsDir),
+	}
+	if err := m.validateConfig(); err != nil {
+		return Model{}, err
 	}
+	if st := loadState(logsDir); st != nil && len(m.stateDiff(st)) > 0 {
+		m.prior = st
+	}
+	return m, nil
+}
 
+// buildSteps lays out the pipeline for config. It is the single registry
+// of steps: everything else goes through the fields set here.
+func buildSteps(config map[string]string) []Step {
+	// Steps whose dependencies are satisfied run concurrently: uv sync and
+	// the Ollama chain start side by side, and LightRAG joins them up.
+	llm := "vllm"
+	if config["llmBackend"] != llmBackendVLLM {
+		llm = "llm"
+	}
 	steps := []Step{
-		{Name: "Python Deps", Description: "Sync Python dependencies (uv sync)", Status: "pending"},
-		{Name: "Ollama", Description: "Check/install Ollama", Status: "pending"},
-		{Name: "Ollama Server", Description: "Start Ollama server", Status: "pending"},
-		{Name: "Embedding Model", Description: "Pull nomic-embed-text", Status: "pending"},
-		{Name: "vLLM Server", Description: "Start vLLM", Status: "pending"},
-		{Name: "LightRAG", Description: "Start RAG pipeline", Status: "pending"},
-		{Name: "HoneyRAG Agent", Description: "Start web agent", Status: "pending"},
+		stepTools: {Name: "Tools", Key: "tools", Description: "Check required tools (uv)", Status: "pending",
+			Run: Model.checkTools, Hint: "looking for uv..."},
+		stepPorts: {Name: "Port Check", Key: "ports", Description: "Check service ports are free", Status: "pending",
+			Run: Model.checkPorts, Hint: "probing ports..."},
+		stepPythonDeps: {Name: "Python Deps", Key: "deps", Description: "Sync Python dependencies (uv sync)", Status: "pending",
+			LogFile: "uv-sync.log", DependsOn: []string{"tools", "ports"},
+			Run: Model.uvSync, Hint: "installing dependencies..."},
+		stepOllamaInstall: {Name: "Ollama", Key: "ollama-install", Description: "Check/install Ollama", Status: "pending",
+			DependsOn: []string{"tools", "ports"},
+			Run:       Model.checkInstallOllama, Hint: "checking installation..."},
+		stepOllamaServer: {Name: "Ollama Server", Key: "ollama", Description: "Start Ollama server", Status: "pending",
+			Service: "ollama", LogFile: "ollama.log", DependsOn: []string{"ollama-install"},
+			Run: Model.startOllama, Hint: "waiting for server..."},
+		stepEmbedding: {Name: "Embedding Model", Key: "embedding", Description: "Pull " + config["embedModel"], Status: "pending",
+			DependsOn: []string{"ollama"},
+			Run:       Model.pullEmbeddingModel, Hint: "looking for " + config["embedModel"] + "..."},
+		stepVLLM: {Name: "vLLM Server", Key: "vllm", Description: "Start vLLM", Status: "pending",
+			Service: "vllm", LogFile: "vllm.log", DependsOn: []string{"tools", "ports", "deps"},
+			Run: Model.startVLLM, Hint: "loading model to GPU...", Extra: Model.vllmConfigView},
+		stepLightRAG: {Name: "LightRAG", Key: "lightrag", Description: "Start RAG pipeline", Status: "pending",
+			Service: "lightrag", LogFile: "lightrag.log", DependsOn: []string{"deps", "ollama", "embedding", llm},
+			Run: Model.startLightRAG, Hint: "initializing RAG..."},
+		stepAgent: {Name: "HoneyRAG Agent", Key: "agent", Description: "Start web agent", Status: "pending",
+			Service: "agent", LogFile: "agent.log", DependsOn: []string{"lightrag"},
+			Run: Model.startAgent, Hint: "starting web UI..."},
 	}
 
-	return Model{
-		steps:     steps,
-		spinner:   s,
-		baseDir:   baseDir,
-		logsDir:   logsDir,
-		ports:     ports,
-		config:    config,
-		processes: make([]*exec.Cmd, 0),
+	if config["llmBackend"] == llmBackendOllama {
+		steps[stepVLLM] = Step{Name: "LLM Model", Key: "llm", Description: "Pull " + config["ollamaModel"] + " (Ollama)", Status: "pending",
+			DependsOn: []string{"ollama"},
+			Run:       Model.pullChatModel, Hint: "checking installed models...", Extra: Model.ollamaLLMConfigView}
 	}
+	if config["llmBackend"] == llmBackendRemote {
+		steps[stepVLLM] = Step{Name: "LLM Endpoint", Key: "llm", Description: "Verify LLM endpoint", Status: "pending",
+			Run: Model.verifyLLMEndpoint, Hint: "listing models...", Extra: Model.remoteLLMConfigView}
+	}
+	// Embedding with vLLM, only the Ollama LLM backend needs Ollama, and
+	// nothing needs embedding models pulled.
+	if config["embeddingBackend"] == embeddingBackendVLLM {
+		drop := ollamaStepKeys
+		if config["llmBackend"] == llmBackendOllama {
+			drop = []string{"embedding"}
+		}
+		steps = slices.DeleteFunc(steps, func(step Step) bool { return slices.Contains(drop, step.Key) })
+		for i := range steps {
+			steps[i].DependsOn = slices.DeleteFunc(slices.Clone(steps[i].DependsOn), func(key string) bool { return slices.Contains(drop, key) })
+		}
+	}
+	// Only when HONEYRAG_DOCS_DIR is set, after every step above.
+	if config["docsDir"] != "" {
+		steps = append(steps, Step{Name: "Documents", Key: "docs", Description: "Ingest " + config["docsDir"] + " into LightRAG", Status: "pending",
+			DependsOn: []string{"lightrag"},
+			Run:       Model.ingestDocs, Hint: "listing indexed documents..."})
+	}
+
+	return steps
+}
+
+// skipSteps marks the steps named in HONEYRAG_SKIP_STEPS, a comma-separated
+// list of step keys, as skipped.
+func skipSteps(steps []Step, names string) error {
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for i := range steps {
+			if steps[i].Key == name {
+				steps[i].Status = "skipped"
+				found = true
+			}
+		}
+		if !found {
+			keys := make([]string, len(steps))
+			for i, step := range steps {
+				keys[i] = step.Key
+			}
+			return fmt.Errorf("HONEYRAG_SKIP_STEPS: unknown step %q (steps: %s)", name, strings.Join(keys, ", "))
+		}
+	}
+	return nil
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.spinner.Tick, m.runStep(0))
+	return tea.Batch(m.spinner.Tick, func() tea.Msg { return pipelineStartMsg{} })
 }
 
 func (m Model) runStep(index int) tea.Cmd {
+	// Read the step here, on the Update goroutine; the command runs on
+	// its own.
+	run := m.steps[index].Run
+	ctx := m.ctx
 	return func() tea.Msg {
-		switch index {
-		case 0:
-			return m.uvSync(index)
-		case 1:
-			return m.checkInstallOllama(index)
-		case 2:
-			return m.startOllama(index)
-		case 3:
-			return m.pullEmbeddingModel(index)
-		case 4:
-			return m.startVLLM(index)
-		case 5:
-			return m.startLightRAG(index)
-		case 6:
-			return m.startAgent(index)
+		if err := run(m, ctx, index); err != nil {
+			return stepErrorMsg{index: index, err: err, cancelled: ctx.Err() != nil}
 		}
 		return stepDoneMsg{index: index}
 	}
 }
 
-func (m Model) uvSync(index int) tea.Msg {
-	// Try with --python flag first to handle systems with multiple Python versions
-	// vLLM requires Python <3.14, so we prefer 3.12 or 3.13
-	pythonVersions := []string{"3.12", "3.13", "3.11", ""}
+// execStep runs the body of step index to completion, giving up early when
+// ctx is cancelled. The non-interactive runners call it directly.
+func (m Model) execStep(ctx context.Context, index int) error {
+	return m.steps[index].Run(m, ctx, index)
+}
 
-	var lastErr error
-	var lastOutput []byte
+func (m Model) uvSync(ctx context.Context, index int) error {
+	// Without uv every step below would fail the same way.
+	if uvBinary() == "" && !m.dryRun {
+		return fmt.Errorf("uv is not installed: %s", uvInstallHint())
+	}
 
-	for _, pyVer := range pythonVersions {
-		var cmd *exec.Cmd
+	pyVer, err := m.choosePython(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	var logFile *serviceLog
+	var failures []uvSyncFailure
+	for attempt := 0; ; attempt++ {
+		args := []string{"sync"}
 		if pyVer != "" {
-			cmd = exec.Command("uv", "sync", "--python", pyVer)
-		} else {
-			cmd = exec.Command("uv", "sync")
+			args = append(args, "--python", pyVer)
+		}
+		if runtime.GOOS == "windows" {
+			// vLLM has no Windows build; see checkPlatform.
+			args = append(args, "--no-install-package", "vllm")
 		}
+		cmd := exec.CommandContext(ctx, uvCommand(), args...)
 		cmd.Dir = m.baseDir
-		output, err := cmd.CombinedOutput()
+		if m.dryRun {
+			m.showCommand(cmd)
+			m.showAction("retry it up to %d times on failure", len(uvSyncBackoff))
+			return nil
+		}
+		if logFile == nil {
+			if logFile, err = m.openLog("uv-sync"); err != nil {
+				return fmt.Errorf("failed to create log file: %v", err)
+			}
+			defer logFile.Close()
+		}
+
+		// Only the end of the output goes into the error; the rest is in
+		// the log.
+		var tail []string
+		output := &lineWriter{
+			file: logFile,
+			onLine: func(line string, redraw bool) {
+				m.notifier.notify(logUpdateMsg{index: index, line: line, redraw: redraw, info: uvSyncPhase(line)})
+				if !redraw {
+					tail = append(tail, line)
+					if len(tail) > uvSyncErrorLines {
+						tail = tail[1:]
+					}
+				}
+			},
+		}
+		cmd.Stdout = output
+		cmd.Stderr = output
+		err := cmd.Run()
 		if err == nil {
-			return stepDoneMsg{index: index}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		failure := uvSyncFailure{python: pyVer, err: err, output: strings.Join(tail, "\n")}
+		failures = append(failures, failure)
+		if failure.missingPython() || attempt == len(uvSyncBackoff) {
+			break
+		}
+
+		wait := uvSyncBackoff[attempt]
+		retry := fmt.Sprintf("uv sync %s failed (%s), retrying in %s", pythonLabel(pyVer), firstLine(err), wait)
+		m.notifier.notify(logUpdateMsg{index: index, line: retry})
+		m.logStep(index, "%s", retry)
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
 		}
-		lastErr = err
-		lastOutput = output
 	}
 
-	return stepErrorMsg{index: index, err: fmt.Errorf("uv sync failed: %v\n%s", lastErr, string(lastOutput))}
+	f := mostInformative(failures)
+	return fmt.Errorf("uv sync %s failed after %d attempts: %v. Last output (full log in logs/uv-sync.log):\n%s",
+		pythonLabel(f.python), len(failures), f.err, f.output)
 }
 
-func (m Model) checkInstallOllama(index int) tea.Msg {
-	_, err := exec.LookPath("ollama")
-	if err == nil {
-		return stepDoneMsg{index: index}
+const ollamaDownloadURL = "https://ollama.com/download"
+
+func (m Model) checkInstallOllama(ctx context.Context, index int) error {
+	// A server that already answers is all the later steps need, whether
+	// or not the CLI is on PATH (e.g. Ollama running in Docker).
+	if m.verifyService(ctx, "ollama") {
+		return nil
+	}
+	if m.ollamaRemote() {
+		return fmt.Errorf("Ollama API at %s (OLLAMA_HOST) is not reachable. Start Ollama on that host and retry", m.ollamaURL(""))
+	}
+	if path := ollamaBinary(); path != "" {
+		m.notifier.notify(logUpdateMsg{index: index, line: "found " + path})
+		return nil
 	}
 
-	cmd := exec.Command("bash", "-c", "curl -fsSL https://ollama.ai/install.sh | sh")
-	output, err := cmd.CombinedOutput()
+	brew := homebrew()
+	if m.dryRun {
+		if brew != "" {
+			m.showCommand(exec.Command(brew, "install", "ollama"))
+			m.showAction("run it only after asking, or with --yes")
+			return nil
+		}
+		archive, _ := ollamaArchive()
+		dir, _, _ := ollamaInstallDir()
+		m.showAction("download %s%s (after asking, or with --yes) and unpack it into %s", ollamaReleaseURL, archive, dir)
+		return nil
+	}
+	if !m.consent.ollamaInstall {
+		return fmt.Errorf("Ollama is not installed. %s", ollamaManualInstall())
+	}
+
+	var path string
+	var err error
+	if brew != "" {
+		path, err = m.brewInstallOllama(ctx, index, brew)
+	} else {
+		path, err = installOllama(ctx, func(completed, total int64) {
+			m.notifier.notify(stepProgressMsg{index: index, completed: completed, total: total})
+		})
+	}
 	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to install Ollama: %s", string(output))}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to install Ollama: %v. Install it from %s and retry", err, ollamaDownloadURL)
 	}
-	return stepDoneMsg{index: index}
+	m.notifier.notify(logUpdateMsg{index: index, line: "installed " + path})
+	return nil
 }
 
-func (m Model) startOllama(index int) tea.Msg {
-	healthURL := fmt.Sprintf("http://localhost:%s/api/tags", m.ports["ollama"])
+func (m Model) startOllama(ctx context.Context, index int) error {
+	if running, err := m.reuseService(ctx, index); running || err != nil {
+		return err
+	}
+	if err := checkPortAvailable(m.bindHost("ollama"), m.port("ollama")); err != nil {
+		return err
+	}
 
-	if isHealthy(healthURL) {
-		return stepDoneMsg{index: index}
+	binary := ollamaBinary()
+	if binary == "" && !m.dryRun {
+		return fmt.Errorf("Ollama is not installed. %s", ollamaManualInstall())
+	}
+	if binary == "" {
+		// Only installed by the previous step.
+		binary = "ollama"
+	}
+	cmd := exec.Command(binary, "serve")
+	cmd.Dir = m.baseDir
+	if m.config["ollamaHost"] == "" {
+		// Without OLLAMA_HOST the server binds 11434 whatever OLLAMA_PORT says.
+		cmd.Env = append(os.Environ(), "OLLAMA_HOST="+net.JoinHostPort(m.bindHost("ollama"), m.port("ollama")))
+	}
+	if m.dryRun {
+		m.showCommand(cmd)
+		return nil
 	}
 
-	logFile, err := os.Create(filepath.Join(m.logsDir, "ollama.log"))
+	logFile, err := m.openLog("ollama")
 	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to create log file: %v", err)}
+		return fmt.Errorf("failed to create log file: %v", err)
 	}
-
-	cmd := exec.Command("ollama", "serve")
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
-	err = cmd.Start()
+	if m.debugEnv {
+		logFile.writeEnv(cmd.Environ())
+	}
+	output := m.stepLogWriter(index, logFile)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	proc, err := m.processes.start("ollama", cmd)
 	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to start Ollama: %v", err)}
+		return fmt.Errorf("failed to start Ollama: %v", err)
 	}
 
-	if !waitForHealthy(healthURL, 30) {
-		return stepErrorMsg{index: index, err: fmt.Errorf("Ollama failed to start (timeout)")}
+	if err := m.waitForHealthy(ctx, index, "ollama", m.timeouts["ollama"], proc); err != nil {
+		return fmt.Errorf("Ollama %w. Last logs:\n%s", err, readLastLines(logFile.path, 20))
 	}
 
-	return stepDoneMsg{index: index}
+	return nil
+}
+
+// embedModels returns the Ollama models named in OLLAMA_EMBED_MODEL, which
+// may be a comma-separated list.
+func (m Model) embedModels() []string {
+	var models []string
+	for _, name := range strings.Split(m.config["embedModel"], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			models = append(models, name)
+		}
+	}
+	return models
 }
 
-func (m Model) pullEmbeddingModel(index int) tea.Msg {
-	time.Sleep(2 * time.Second)
+func (m Model) pullEmbeddingModel(ctx context.Context, index int) error {
+	return m.pullOllamaModels(ctx, index, m.embedModels())
+}
 
-	for i := 0; i < 3; i++ {
-		cmd := exec.Command("ollama", "list")
-		output, err := cmd.Output()
-		if err == nil && strings.Contains(string(output), "nomic-embed-text") {
-			return stepDoneMsg{index: index}
+// pullOllamaModels pulls every model in models the Ollama server doesn't
+// have yet, reporting each one's outcome on the step's log lines.
+func (m Model) pullOllamaModels(ctx context.Context, index int, models []string) error {
+	if m.dryRun {
+		m.showAction("pull %s from %s unless already present", strings.Join(models, ", "), m.ollamaURL("/api/pull"))
+		return nil
+	}
+
+	// One look is usually enough. If it fails or a model is missing,
+	// Ollama may still be warming up, so look again for a few seconds
+	// before pulling.
+	installed, err := m.ollamaModels(ctx)
+	for i := 0; i < 3 && (err != nil || !hasModels(installed, models)); i++ {
+		if err := sleepContext(ctx, 1*time.Second); err != nil {
+			return err
+		}
+		var names []string
+		if names, err = m.ollamaModels(ctx); err == nil {
+			installed = names
 		}
-		time.Sleep(1 * time.Second)
 	}
 
-	cmd := exec.Command("ollama", "pull", "nomic-embed-text")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to pull: %v - %s", err, string(output))}
+	var failed []string
+	for _, model := range models {
+		if hasModel(installed, model) {
+			m.notifier.notify(logUpdateMsg{index: index, line: model + ": already present"})
+			continue
+		}
+		err := m.ollamaPull(ctx, model, func(completed, total int64) {
+			m.notifier.notify(stepProgressMsg{index: index, completed: completed, total: total})
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			m.notifier.notify(logUpdateMsg{index: index, line: model + ": failed"})
+			failed = append(failed, fmt.Sprintf("  %s: %v", model, err))
+			continue
+		}
+		m.notifier.notify(logUpdateMsg{index: index, line: model + ": pulled"})
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to pull:\n%s", strings.Join(failed, "\n"))
 	}
 
-	return stepDoneMsg{index: index}
+	return nil
 }
 
-func (m *Model) startVLLM(index int) tea.Msg {
-	healthURL := fmt.Sprintf("http://localhost:%s/v1/models", m.ports["vllm"])
+func (m Model) startVLLM(ctx context.Context, index int) error {
+	if running, err := m.reuseService(ctx, index); running || err != nil {
+		return err
+	}
 
-	if isHealthy(healthURL) {
-		return stepDoneMsg{index: index}
+	if err := m.gpuPreflight(); err != nil {
+		return err
 	}
 
-	logPath := filepath.Join(m.logsDir, "vllm.log")
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to create log file: %v", err)}
+	// The download check may need the user's consent, which a dry run
+	// doesn't ask for.
+	if !m.dryRun {
+		if err := m.modelPreflight(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := checkPortAvailable(m.bindHost("vllm"), m.port("vllm")); err != nil {
+		return err
+	}
+
+	// A CUDA OOM is usually down to other programs holding VRAM or settings
+	// that are a little too greedy, so retry with smaller ones before
+	// giving up.
+	gpuUtil, maxLen := m.config["gpuUtil"], m.config["maxLen"]
+	var tried []string
+	for attempt := 0; ; attempt++ {
+		logPath, err := m.runVLLM(ctx, index, gpuUtil, maxLen)
+		if !errors.Is(err, errVLLMOOM) {
+			return err
+		}
+		tried = append(tried, fmt.Sprintf("--gpu-memory-utilization %s --max-model-len %s", gpuUtil, maxLen))
+
+		next, nextLen, ok := oomFallback(gpuUtil, maxLen)
+		if !ok || attempt == vllmOOMRetries || m.config["device"] == deviceCPU {
+			return fmt.Errorf("vLLM ran out of GPU memory with every setting tried:\n  %s\n"+
+				"The log said: %s\n"+
+				"Lower --gpu-memory-utilization (VLLM_GPU_MEMORY_UTILIZATION) or --max-model-len (VLLM_MAX_MODEL_LEN) in configs/.env, "+
+				"choose a smaller VLLM_MODEL, or free VRAM used by other programs. Last logs:\n%s",
+				strings.Join(tried, "\n  "), strings.TrimPrefix(err.Error(), errVLLMOOM.Error()+": "), readLastLines(logPath, 20))
+		}
+		gpuUtil, maxLen = next, nextLen
+		retry := fmt.Sprintf("out of GPU memory, retrying with --gpu-memory-utilization %s --max-model-len %s", gpuUtil, maxLen)
+		m.notifier.notify(logUpdateMsg{index: index, line: retry})
+		m.logStep(index, "%s", retry)
 	}
+}
 
-	cmd := exec.Command("uv", "run", "vllm", "serve", m.config["model"],
-		"--port", m.ports["vllm"],
-		"--gpu-memory-utilization", m.config["gpuUtil"],
-		"--max-model-len", m.config["maxLen"],
-		"--enforce-eager")
+// runVLLM starts vLLM with the given memory settings and waits for it to
+// become healthy. It returns the log path, and errVLLMOOM, wrapped with the
+// line that gave it away, if vLLM reported running out of GPU memory, after
+// making sure the failed process is gone.
+func (m Model) runVLLM(ctx context.Context, index int, gpuUtil, maxLen string) (string, error) {
+	args := []string{"run", "vllm", "serve", m.config["model"],
+		"--host", m.bindHost("vllm"),
+		"--port", m.port("vllm"),
+		"--max-model-len", maxLen,
+		"--enforce-eager"}
+	args = append(args, m.vllmDeviceArgs(gpuUtil)...)
+	// Validated in initialModel.
+	extra, _ := vllmExtraArgs(m.config)
+	args = append(args, extra...)
+	cmd := exec.Command(uvCommand(), args...)
 	cmd.Dir = m.baseDir
+	if m.dryRun {
+		m.showCommand(cmd)
+		m.showAction("send %s one-token completions until one succeeds (up to %s)", m.serviceURL("vllm", "/v1/completions"), m.timeouts["vllmWarmup"])
+		return "", nil
+	}
 
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
+	logFile, err := m.openLog("vllm")
+	if err != nil {
+		return "", fmt.Errorf("failed to create log file: %v", err)
+	}
+	if m.debugEnv {
+		logFile.writeEnv(cmd.Environ())
+	}
+	logPath := logFile.path
+
+	// vLLM can hang on after a worker runs out of memory, so stop waiting
+	// as soon as the error shows up in its output.
+	oomCtx, oom := context.WithCancel(ctx)
+	defer oom()
+	var oomSeen, ready atomic.Bool
+	// oomLine and killedLine keep the first line reporting a GPU OOM or a
+	// kill, for the error.
+	var oomLine, killedLine atomic.Value
+	var download hfProgress
+	output := &lineWriter{
+		file: logFile,
+		onLine: func(line string, redraw bool) {
+			// Once serving, vLLM's request stats mention the KV cache too.
+			info := ""
+			if !ready.Load() {
+				info = vllmPhase(line)
+			}
+			m.notifier.notify(logUpdateMsg{index: index, line: line, redraw: redraw, info: info})
+			if completed, total, ok := download.update(line); ok {
+				m.notifier.notify(stepProgressMsg{index: index, completed: completed, total: total})
+			}
+			if isOOMLine(line) {
+				oomLine.CompareAndSwap(nil, strings.TrimSpace(line))
+				oomSeen.Store(true)
+				oom()
+			}
+			if isKilledLine(line) {
+				killedLine.CompareAndSwap(nil, strings.TrimSpace(line))
+			}
+		},
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
 
-	err = cmd.Start()
+	proc, err := m.processes.start("vllm", cmd)
 	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to start vLLM: %v", err)}
+		return logPath, fmt.Errorf("failed to start vLLM: %v", err)
 	}
 
-	go func() {
-		multi := io.MultiReader(stdout, stderr)
-		scanner := bufio.NewScanner(multi)
-		for scanner.Scan() {
-			line := scanner.Text()
-			logFile.WriteString(line + "\n")
+	err = m.waitForHealthy(oomCtx, index, "vllm", m.timeouts["vllm"], proc)
+	var ttft time.Duration
+	if err == nil {
+		ttft, err = m.vllmWarmup(oomCtx, index, proc)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return logPath, ctx.Err()
 		}
-	}()
-
-	if !waitForHealthy(healthURL, 300) {
-		logContent := readLastLines(logPath, 5)
-		return stepErrorMsg{index: index, err: fmt.Errorf("vLLM timeout. Last logs:\n%s", logContent)}
+		if oomSeen.Load() {
+			m.processes.stop("vllm", 5*time.Second)
+			return logPath, fmt.Errorf("%w: %s", errVLLMOOM, oomLine.Load())
+		}
+		if line, ok := killedLine.Load().(string); ok {
+			return logPath, vllmKilledError(line, logPath)
+		}
+		if proc.exited() && killedOutright(proc.cmd.ProcessState) {
+			return logPath, vllmKilledError(proc.cmd.ProcessState.String(), logPath)
+		}
+		if m.config["device"] == deviceCPU {
+			return logPath, fmt.Errorf("vLLM %w. vLLM ran on CPU, which needs a CPU build of vLLM "+
+				"and a small model (e.g. VLLM_MODEL=Qwen/Qwen2.5-0.5B-Instruct). Last logs:\n%s", err, readLastLines(logPath, 20))
+		}
+		return logPath, fmt.Errorf("vLLM %w. Last logs:\n%s", err, readLastLines(logPath, 20))
 	}
 
-	return stepDoneMsg{index: index}
+	ready.Store(true)
+	m.notifier.notify(logUpdateMsg{index: index, line: "ready",
+		info: fmt.Sprintf("serving %s%sfirst token in %s", m.config["model"], glyphs.sep, ttft.Round(time.Millisecond))})
+	return logPath, nil
 }
 
-func (m *Model) startLightRAG(index int) tea.Msg {
-	healthURL := fmt.Sprintf("http://localhost:%s/health", m.ports["lightrag"])
-
-	if isHealthy(healthURL) {
-		return stepDoneMsg{index: index}
+func (m Model) startLightRAG(ctx context.Context, index int) error {
+	if running, err := m.reuseService(ctx, index); running || err != nil {
+		return err
 	}
 
-	logPath := filepath.Join(m.logsDir, "lightrag.log")
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to create log file: %v", err)}
+	if err := checkPortAvailable(m.bindHost("lightrag"), m.port("lightrag")); err != nil {
+		return err
 	}
 
-	cmd := exec.Command("uv", "run", "lightrag-server")
+	cmd := exec.Command(uvCommand(), "run", "lightrag-server", "--host", m.bindHost("lightrag"), "--port", m.port("lightrag"))
 	cmd.Dir = m.baseDir
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Env = m.serviceEnv()
+	if m.dryRun {
+		m.showCommand(cmd)
+		if path := m.config["lightragReadyPath"]; path != "" {
+			m.showAction("wait, once /health answers, for %s to say LightRAG isn't busy (up to %s)", m.serviceURL("lightrag", path), m.timeouts["lightragReady"])
+		}
+		return nil
+	}
 
-	err = cmd.Start()
+	logFile, err := m.openLog("lightrag")
 	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to start LightRAG: %v", err)}
+		return fmt.Errorf("failed to create log file: %v", err)
 	}
+	if m.debugEnv {
+		logFile.writeEnv(cmd.Environ())
+	}
+	logPath := logFile.path
+	output := m.stepLogWriter(index, logFile)
+	cmd.Stdout = output
+	cmd.Stderr = output
 
-	if !waitForHealthy(healthURL, 60) {
-		logContent := readLastLines(logPath, 5)
-		return stepErrorMsg{index: index, err: fmt.Errorf("LightRAG timeout. Last logs:\n%s", logContent)}
+	proc, err := m.processes.start("lightrag", cmd)
+	if err != nil {
+		return fmt.Errorf("failed to start LightRAG: %v", err)
 	}
 
-	return stepDoneMsg{index: index}
-}
+	if err := m.waitForHealthy(ctx, index, "lightrag", m.timeouts["lightrag"], proc); err != nil {
+		return fmt.Errorf("LightRAG %w. Last logs:\n%s", err, readLastLines(logPath, 20))
+	}
+	// /health answers before LightRAG has loaded its storage or finished
+	// indexing, and the agent would be turned away.
+	if path := m.config["lightragReadyPath"]; path != "" {
+		if err := m.waitForReady(ctx, index, "lightrag", path, m.timeouts["lightragReady"], proc); err != nil {
+			return fmt.Errorf("LightRAG %w. Last logs:\n%s", err, readLastLines(logPath, 20))
+		}
+	}
 
-func (m *Model) startAgent(index int) tea.Msg {
-	healthURL := fmt.Sprintf("http://localhost:%s/health", m.ports["agno"])
+	return nil
+}
 
-	if isHealthy(healthURL) {
-		return stepDoneMsg{index: index}
+func (m Model) startAgent(ctx context.Context, index int) error {
+	if running, err := m.reuseService(ctx, index); running || err != nil {
+		return err
 	}
 
-	logPath := filepath.Join(m.logsDir, "agent.log")
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to create log file: %v", err)}
+	if err := checkPortAvailable(m.bindHost("agno"), m.port("agno")); err != nil {
+		return err
 	}
 
-	cmd := exec.Command("uv", "run", "uvicorn", "app:app", "--host", "0.0.0.0", "--port", m.ports["agno"])
+	cmd := exec.Command(uvCommand(), "run", "uvicorn", "app:app", "--host", m.bindHost("agno"), "--port", m.port("agno"))
 	cmd.Dir = filepath.Join(m.baseDir, "services", "agno")
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Env = m.serviceEnv()
+	if m.dryRun {
+		m.showCommand(cmd)
+		return nil
+	}
 
-	err = cmd.Start()
+	logFile, err := m.openLog("agent")
 	if err != nil {
-		return stepErrorMsg{index: index, err: fmt.Errorf("failed to start Agent: %v", err)}
+		return fmt.Errorf("failed to create log file: %v", err)
+	}
+	if m.debugEnv {
+		logFile.writeEnv(cmd.Environ())
+	}
+	logPath := logFile.path
+	output := m.stepLogWriter(index, logFile)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	proc, err := m.processes.start("agent", cmd)
+	if err != nil {
+		return fmt.Errorf("failed to start Agent: %v", err)
 	}
 
-	if !waitForHealthy(healthURL, 30) {
-		logContent := readLastLines(logPath, 5)
-		return stepErrorMsg{index: index, err: fmt.Errorf("Agent timeout. Last logs:\n%s", logContent)}
+	if err := m.waitForHealthy(ctx, index, "agno", m.timeouts["agent"], proc); err != nil {
+		return fmt.Errorf("Agent %w. Last logs:\n%s", err, readLastLines(logPath, 20))
 	}
 
-	return stepDoneMsg{index: index}
+	return nil
 }
 
-func isHealthy(url string) bool {
-	client := http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get(url)
+// healthURL is the endpoint polled to decide whether service is up.
+func (m Model) healthURL(service string) string {
+	path := "/health"
+	switch service {
+	case "ollama":
+		path = "/api/tags"
+	case "vllm":
+		path = "/v1/models"
+	}
+	return m.serviceURL(service, path)
+}
+
+// fetchHealth GETs url, with token as a bearer token if it is set, and
+// returns the body if it answered 200 within timeout.
+func fetchHealth(ctx context.Context, url, token string, timeout time.Duration) ([]byte, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return false
+		return nil, false
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false
 	}
 	defer resp.Body.Close()
-	return resp.StatusCode == 200
+	if resp.StatusCode != 200 {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return body, err == nil
 }
 
-func waitForHealthy(url string, timeoutSeconds int) bool {
-	for i := 0; i < timeoutSeconds; i++ {
-		if isHealthy(url) {
-			return true
+// verifyService checks that service's health endpoint answers and, where the
+// response identifies the server, that it really is the expected service:
+// Ollama must return a model list and vLLM must be serving the configured
+// model. A foreign process squatting on the port fails this check.
+func (m Model) verifyService(ctx context.Context, service string) bool {
+	token := ""
+	if service == "vllm" {
+		token = m.config["vllmAPIKey"]
+	}
+	body, ok := fetchHealth(ctx, m.healthURL(service), token, m.probe.timeout)
+	if !ok {
+		return false
+	}
+
+	switch service {
+	case "ollama":
+		var tags struct {
+			Models []json.RawMessage `json:"models"`
+		}
+		return json.Unmarshal(body, &tags) == nil && tags.Models != nil
+	case "vllm":
+		served, ok := vllmServedModels(body)
+		return ok && slices.Contains(served, m.config["model"])
+	}
+	return true
+}
+
+// waitForHealthy polls service once a second until verifyService passes. It
+// fails as soon as proc exits, when timeout expires or when ctx is
+// cancelled. The deadline is reported to the TUI so it can show the time
+// left for step index.
+func (m Model) waitForHealthy(ctx context.Context, index int, service string, timeout time.Duration, proc *managedProcess) error {
+	deadline := time.Now().Add(timeout)
+	m.notifier.notify(stepDeadlineMsg{index: index, deadline: deadline})
+
+	// Checking straight away only fills the log with refused connections.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-proc.done:
+		return fmt.Errorf("exited during startup (%v)", proc.cmd.ProcessState)
+	case <-time.After(m.probe.initialDelay):
+	}
+
+	ticker := time.NewTicker(m.probe.interval)
+	defer ticker.Stop()
+
+	for {
+		if m.verifyService(ctx, service) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return timeoutError(fmt.Sprintf("timed out after %s", timeout))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-proc.done:
+			return fmt.Errorf("exited during startup (%v)", proc.cmd.ProcessState)
+		case <-ticker.C:
 		}
-		time.Sleep(1 * time.Second)
 	}
-	return false
 }
 
+// waitForReady is the second phase of starting a service whose health
+// endpoint answers before it can do its job: it polls readyPath until that
+// answers 200 with a body that doesn't say it is busy (LightRAG's pipeline
+// status while it loads or indexes documents). It fails like waitForHealthy.
+func (m Model) waitForReady(ctx context.Context, index int, service, readyPath string, timeout time.Duration, proc *managedProcess) error {
+	deadline := time.Now().Add(timeout)
+	m.notifier.notify(stepDeadlineMsg{index: index, deadline: deadline})
+
+	ticker := time.NewTicker(m.probe.interval)
+	defer ticker.Stop()
+
+	last := "no answer yet"
+	for {
+		ready, why := m.checkReady(ctx, service, readyPath)
+		if ready {
+			return nil
+		}
+		if why != last {
+			last = why
+			m.notifier.notify(logUpdateMsg{index: index, line: "not ready: " + why, info: why})
+		}
+		if time.Now().After(deadline) {
+			return timeoutError(fmt.Sprintf("answered its health check but %s was not ready within %s: %s", readyPath, timeout, last))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-proc.done:
+			return fmt.Errorf("exited while getting ready (%v)", proc.cmd.ProcessState)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkReady makes one readiness check of service at path. If it isn't
+// ready, why says what it answered.
+func (m Model) checkReady(ctx context.Context, service, path string) (ready bool, why string) {
+	ctx, cancel := context.WithTimeout(ctx, m.probe.timeout)
+	defer cancel()
+	var resp *http.Response
+	var err error
+	if service == "lightrag" {
+		resp, err = m.lightragRequest(ctx, http.MethodGet, path, "", nil)
+	} else {
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, m.serviceURL(service, path), nil); err == nil {
+			resp, err = http.DefaultClient.Do(req)
+		}
+	}
+	if err != nil {
+		return false, "no answer yet"
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "answered " + resp.Status
+	}
+	var status struct {
+		Busy          bool   `json:"busy"`
+		PipelineBusy  bool   `json:"pipeline_busy"`
+		LatestMessage string `json:"latest_message"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&status) == nil && (status.Busy || status.PipelineBusy) {
+		if message, _, _ := strings.Cut(status.LatestMessage, "\n"); message != "" {
+			return false, "busy: " + message
+		}
+		return false, "busy loading or indexing documents"
+	}
+	return true, ""
+}
+
+// sleepContext sleeps for d or until ctx is cancelled, returning ctx.Err()
+// in the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// tailChunkSize is how much readLastLines reads at a time, working back
+// from the end of the file.
+const tailChunkSize = 64 << 10
+
+// tailMaxLine caps each line readLastLines returns; vLLM prints whole
+// config dumps on a single line.
+const tailMaxLine = 4 << 10
+
+// readLastLines returns the last n lines of filePath joined by newlines. It
+// scans backwards from the end in chunks for the newlines that delimit them,
+// so tailing a log of hundreds of megabytes costs no more than a small one,
+// and then reads at most tailMaxLine bytes of each line, cutting longer ones
+// short.
 func readLastLines(filePath string, n int) string {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Sprintf("(could not read log: %v)", err)
 	}
 	defer file.Close()
+	if n <= 0 {
+		return ""
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Sprintf("(could not read log: %v)", err)
+	}
+
+	// bounds holds the offsets of the newlines found, last first. A line
+	// runs from just after one bound to the next; the start of the file and
+	// its end, unless the file ends in a newline, act as bounds too.
+	size := info.Size()
+	bounds := []int64{size}
+	chunk := make([]byte, tailChunkSize)
+	for offset := size; offset > 0 && len(bounds) < n+1; {
+		read := min(int64(tailChunkSize), offset)
+		offset -= read
+		if _, err := file.ReadAt(chunk[:read], offset); err != nil && err != io.EOF {
+			return fmt.Sprintf("(could not read log: %v)", err)
+		}
+		for i := read - 1; i >= 0 && len(bounds) < n+1; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+			if pos := offset + i; pos == size-1 {
+				bounds[0] = pos
+			} else {
+				bounds = append(bounds, pos)
+			}
+		}
+	}
+	if len(bounds) < n+1 {
+		bounds = append(bounds, -1)
+	}
+	slices.Reverse(bounds)
 
 	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-		if len(lines) > n {
-			lines = lines[1:]
+	for i := 1; i < len(bounds); i++ {
+		start, end := bounds[i-1]+1, bounds[i]
+		line := make([]byte, min(end-start, tailMaxLine))
+		if _, err := file.ReadAt(line, start); err != nil && err != io.EOF {
+			return fmt.Sprintf("(could not read log: %v)", err)
+		}
+		text := strings.TrimSuffix(string(line), "\r")
+		if end-start > tailMaxLine {
+			text = strings.ToValidUTF8(text, "") + fmt.Sprintf("... (%s)", formatBytes(end-start))
 		}
+		lines = append(lines, text)
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
 	}
 	return strings.Join(lines, "\n")
 }
 
+func (m Model) stopServices() tea.Cmd {
+	return func() tea.Msg {
+		m.processes.stopAll(5 * time.Second)
+		return servicesStoppedMsg{}
+	}
+}
+
+type endpoint struct {
+	label string
+	url   string
+}
+
+// endpoints lists the user-facing URLs shown once the stack is up.
+func (m Model) endpoints() []endpoint {
+	var list []endpoint
+	if m.usesStep("agent") {
+		list = append(list, endpoint{"Agent UI", m.serviceURL("agno", "")})
+	}
+	if m.usesStep("lightrag") {
+		list = append(list, endpoint{"LightRAG UI", m.serviceURL("lightrag", "")})
+	}
+	if m.usesStep("vllm") || m.usesStep("llm") {
+		list = append(list, m.llmEndpoint())
+	}
+	return list
+}
+
+// llmEndpoint is the OpenAI-compatible API serving the chat model.
+func (m Model) llmEndpoint() endpoint {
+	if m.ollamaBackend() {
+		return endpoint{"Ollama API", m.ollamaURL("/v1")}
+	}
+	if m.remoteLLM() {
+		return endpoint{"LLM API", m.remoteLLMBase()}
+	}
+	return endpoint{"vLLM API", m.serviceURL("vllm", "")}
+}
+
+// dispatchReady starts every pending step whose dependencies have finished
+// and marks the pipeline done once every step is done or skipped. Nothing new
+// is started while a failure is waiting on the user; the steps that were
+// running alongside it are cancelled and queued again.
+func (m *Model) dispatchReady() tea.Cmd {
+	if m.err != nil || m.quitting || m.prior != nil {
+		return nil
+	}
+
+	var cmds []tea.Cmd
+	finished := 0
+	for i := range m.steps {
+		switch m.steps[i].Status {
+		case "done", "skipped":
+			finished++
+		case "pending":
+			if m.dependenciesMet(i) {
+				m.startStep(i)
+				m.logStep(i, "running")
+				m.emitStep(i, nil)
+				cmds = append(cmds, m.runStep(i))
+			}
+		}
+	}
+
+	if len(cmds) > 0 && m.startedAt.IsZero() {
+		// The --timeout clock starts here, not at launch, so time spent on
+		// the saved-state prompt doesn't count.
+		m.startedAt = time.Now()
+		cmds = append(cmds, m.runDeadline())
+	}
+	if finished == len(m.steps) && !m.done {
+		m.done = true
+		m.finishedAt = time.Now()
+		m.saveState()
+		m.writeSummary(nil)
+		m.clearFailure()
+		m.emitPipeline()
+		if !m.monitoring {
+			m.monitoring = true
+			cmds = append(cmds, m.healthTick())
+			if !m.jsonStream {
+				cmds = append(cmds, m.sampleUsage())
+			}
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// startStep marks step index as running from now.
+func (m *Model) startStep(index int) {
+	m.renewContext()
+	m.steps[index].Status = "running"
+	m.steps[index].StartedAt = time.Now()
+	m.steps[index].FinishedAt = time.Time{}
+	m.steps[index].Info = ""
+	m.steps[index].Reused = false
+	m.steps[index].DownSince = time.Time{}
+}
+
+// elapsed is how long the step has been running, or ran for.
+func (s Step) elapsed() time.Duration {
+	if s.FinishedAt.IsZero() {
+		return time.Since(s.StartedAt)
+	}
+	return s.FinishedAt.Sub(s.StartedAt)
+}
+
+// formatElapsed renders d to the second, e.g. "1m23s".
+func formatElapsed(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func (m Model) dependenciesMet(index int) bool {
+	for _, dep := range m.steps[index].DependsOn {
+		for _, step := range m.steps {
+			if step.Key == dep && step.Status != "done" && step.Status != "skipped" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// restartKey maps the step number pressed after 'R' to a service step that
+// can be restarted: one that has finished or failed.
+func (m Model) restartKey(key string) (int, bool) {
+	n, err := strconv.Atoi(key)
+	if err != nil || n < 1 || n > len(m.steps) || m.quitting {
+		return 0, false
+	}
+	step := m.steps[n-1]
+	if step.Service == "" || step.External || (step.Status != "done" && step.Status != "error") {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+// restartStep stops the service started by step index, including one left
+// over from an earlier run, and then runs the step again.
+func (m Model) restartStep(index int) tea.Cmd {
+	// Read the step up front; Update keeps mutating m.steps while the
+	// command runs.
+	name := m.steps[index].Service
+	run := m.runStep(index)
+	return func() tea.Msg {
+		if !m.processes.stop(name, 5*time.Second) {
+			if svc, ok := lookupService(name); ok {
+				stopService(filepath.Join(m.logsDir, name+".pid"), svc.command, 5*time.Second)
+			}
+		}
+		return run()
+	}
+}
+
+// quit cancels the running steps and stops every service before the
+// program exits, noting why in the run log.
+func (m Model) quit(reason string) (tea.Model, tea.Cmd) {
+	if m.quitting {
+		return m, nil
+	}
+	m.quitting = true
+	m.events.event("honeyrag", reason)
+	m.cancel()
+	return m, m.stopServices()
+}
+
+// running reports whether the whole stack is up and waiting on the user.
+func (m Model) running() bool {
+	return m.done && m.err == nil && !m.quitting
+}
+
+// restartLegend lists the step numbers that, after 'R', restart each
+// service.
+func (m Model) restartLegend() string {
+	var keys []string
+	for i, step := range m.steps {
+		if step.Service != "" && !step.External {
+			keys = append(keys, fmt.Sprintf("%d %s", i+1, step.Service))
+		}
+	}
+	return "Restart: R then " + strings.Join(keys, glyphs.sep)
+}
+
+// restart runs service step index again, stopping what it started first.
+func (m Model) restart(index int) (tea.Model, tea.Cmd) {
+	if m.steps[index].Status == "error" {
+		m.err = nil
+	}
+	m.startStep(index)
+	m.steps[index].LogLines = nil
+	m.steps[index].Deadline = time.Time{}
+	m.logStep(index, "restart requested")
+	m.emitStep(index, nil)
+	return m, m.restartStep(index)
+}
+
+// prefixedKey finishes an 'R' or 'c' with the number key that follows it.
+// Any other key cancels it.
+func (m Model) prefixedKey(prefix, key string) (tea.Model, tea.Cmd) {
+	switch prefix {
+	case "R":
+		if i, ok := m.restartKey(key); ok {
+			return m.restart(i)
+		}
+	case "c":
+		if e, ok := m.endpointKey(key); ok {
+			copyToClipboard(e.url)
+			m.notice = "Copied " + e.url
+		}
+	}
+	return m, nil
+}
+
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.notice = ""
+		if m.confirmQuit {
+			m.confirmQuit = false
+			if msg.String() == "y" || msg.String() == "Y" || msg.String() == "ctrl+c" {
+				return m.quit("quit requested")
+			}
+			m.notice = "Services left running"
+			return m, nil
+		}
+		if prefix := m.keyPrefix; prefix != "" && msg.String() != "ctrl+c" {
+			m.keyPrefix = ""
+			return m.prefixedKey(prefix, msg.String())
+		}
+		if m.logViewOpen && msg.String() != "ctrl+c" && (msg.String() != "q" || m.logViewSearching) {
+			return m.updateLogView(msg)
+		}
+		if m.prior == nil {
+			if next, ok := m.updateSelection(msg); ok {
+				return next, nil
+			}
+		}
 		switch msg.String() {
-		case "ctrl+c", "q":
-			m.quitting = true
-			return m, tea.Quit
+		case "l":
+			if i := m.focusedStep(); i >= 0 {
+				return m, m.openLogView(i)
+			}
+			return m, nil
+		case "o":
+			// With the stack up and no step picked, 'o' opens the first
+			// endpoint, the Agent UI when the agent runs.
+			if m.running() && m.selected < 0 && len(m.endpoints()) > 0 {
+				return m, openBrowser(m.endpoints()[0].url)
+			}
+			if i := m.focusedStep(); i >= 0 {
+				return m, m.openPager(i)
+			}
+			return m, nil
+		case "q":
+			if m.running() {
+				m.confirmQuit = true
+				return m, nil
+			}
+			return m.quit("quit requested")
+		case "ctrl+c":
+			return m.quit("quit requested")
+		case "u", "enter":
+			if m.prior == nil {
+				return m, nil
+			}
+			if msg.String() == "u" {
+				m.applyState(m.prior)
+			}
+			m.prior = nil
+			return m, m.dispatchReady()
+		case "r", "s":
+			if m.err == nil || m.quitting {
+				return m, nil
+			}
+			// Retry puts failed steps back in the queue; skip treats them
+			// as finished so their dependents can run.
+			status, action := "pending", "retried"
+			if msg.String() == "s" {
+				status, action = "skipped", "skipped"
+			}
+			for i := range m.steps {
+				if m.steps[i].Status == "error" {
+					m.logStep(i, "%s by user", action)
+					m.steps[i].Status = status
+					m.steps[i].LogLines = nil
+					m.steps[i].Deadline = time.Time{}
+					m.steps[i].Completed, m.steps[i].Total = 0, 0
+					m.emitStep(i, nil)
+				}
+			}
+			m.err = nil
+			return m, m.dispatchReady()
+		case "R":
+			if !m.quitting {
+				m.keyPrefix = "R"
+			}
+			return m, nil
+		case "c":
+			if m.running() && len(m.endpoints()) > 0 {
+				m.keyPrefix = "c"
+			}
+			return m, nil
+		default:
+			if e, ok := m.endpointKey(msg.String()); ok {
+				return m, openBrowser(e.url)
+			}
 		}
 
+	case pipelineStartMsg:
+		return m, m.dispatchReady()
+
+	case signalMsg:
+		return m.quit(fmt.Sprintf("received %s", msg.sig))
+
+	case usageTickMsg:
+		if m.quitting {
+			return m, nil
+		}
+		return m, m.sampleUsage()
+
+	case usageMsg:
+		m.usage, m.usageSamples = msg.usage, msg.samples
+		return m, m.usageTick()
+
+	case healthTickMsg:
+		if m.quitting {
+			return m, nil
+		}
+		return m, m.checkHealth()
+
+	case healthResultMsg:
+		if m.quitting {
+			return m, nil
+		}
+		restarts := m.applyHealth(msg)
+		return m, tea.Batch(restarts, m.healthTick())
+
+	case autoRestartMsg:
+		return m, m.autoRestart(msg)
+
+	case servicesStoppedMsg:
+		return m, tea.Quit
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		if m.logViewOpen {
+			m.resizeLogView()
+		}
+		return m, nil
+
+	case logViewTickMsg:
+		return m.pollLogView(msg)
+
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
 
+	case stepProgressMsg:
+		m.steps[msg.index].Completed = msg.completed
+		m.steps[msg.index].Total = msg.total
+		return m, nil
+
+	case pagerClosedMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("Could not show %s: %v", m.stepLogPath(msg.index), msg.err)
+		}
+		return m, nil
+
+	case browserClosedMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("Could not open %s: %v", msg.url, msg.err)
+		}
+		return m, nil
+
+	case stepDeadlineMsg:
+		m.steps[msg.index].Deadline = msg.deadline
+		return m, nil
+
+	case runTimeoutMsg:
+		return m.runTimedOut()
+
 	case stepDoneMsg:
-		m.steps[msg.index].Status = "done"
-		m.currentStep++
-		if m.currentStep >= len(m.steps) {
-			m.done = true
+		if m.steps[msg.index].Status != "running" {
+			// Already failed by the --timeout.
 			return m, nil
 		}
-		m.steps[m.currentStep].Status = "running"
-		return m, m.runStep(m.currentStep)
+		m.steps[msg.index].Status = "done"
+		m.steps[msg.index].FinishedAt = time.Now()
+		m.steps[msg.index].DownSince = time.Time{}
+		m.logStep(msg.index, "done")
+		m.emitStep(msg.index, nil)
+		return m, m.dispatchReady()
 
 	case stepErrorMsg:
+		if m.steps[msg.index].Status != "running" {
+			return m, nil
+		}
+		if msg.cancelled && !m.quitting {
+			// Stopped because another step failed: queue it again, to run
+			// once that one is retried or skipped.
+			m.steps[msg.index].Status = "pending"
+			m.steps[msg.index].LogLines = nil
+			m.steps[msg.index].Completed, m.steps[msg.index].Total = 0, 0
+			m.logStep(msg.index, "cancelled: another step failed")
+			m.emitStep(msg.index, nil)
+			return m, m.dispatchReady()
+		}
 		m.steps[msg.index].Status = "error"
+		m.steps[msg.index].FinishedAt = time.Now()
+		m.logStep(msg.index, "failed: %s", firstLine(msg.err))
+		m.emitStep(msg.index, msg.err)
 		m.err = msg.err
+		m.errHint = m.matchErrorHint(msg.index, msg.err)
+		m.writeSummary(msg.err)
+		m.writeFailure(msg.index, msg.err)
+		// Stop the steps running alongside it rather than let them carry
+		// on for minutes behind the error.
+		m.cancel()
+		if m.jsonStream && !m.quitting {
+			// Nobody is there to retry or skip; give up like headless mode.
+			m.quitting = true
+			return m, m.stopServices()
+		}
+		if !m.steps[msg.index].DownSince.IsZero() && !m.quitting {
+			// An automatic restart failed.
+			return m, m.scheduleRestart(msg.index)
+		}
 		return m, nil
 
 	case logUpdateMsg:
-		m.logMutex.Lock()
+		if m.jsonEvents && msg.line != "" && !msg.redraw {
+			m.emitLogEvent(msg.index, msg.line)
+		}
 		step := &m.steps[msg.index]
-		step.LogLines = append(step.LogLines, msg.line)
-		if len(step.LogLines) > 3 {
-			step.LogLines = step.LogLines[len(step.LogLines)-3:]
+		replace := msg.redraw && step.redrawing && len(step.LogLines) > 0
+		step.redrawing = msg.redraw
+		if msg.info != "" {
+			step.Info = msg.info
+		}
+		if msg.reused {
+			step.Reused = true
+		}
+		if replace {
+			step.LogLines[len(step.LogLines)-1] = msg.line
+		} else {
+			step.LogLines = append(step.LogLines, msg.line)
+		}
+		if keep := m.logLinesKept(); len(step.LogLines) > keep {
+			step.LogLines = step.LogLines[len(step.LogLines)-keep:]
+		}
+		// A pane following a log file picks the line up from there.
+		if m.logViewOpen && m.logViewStep == msg.index && m.logViewFollow == nil {
+			if replace {
+				m.replaceLogViewLine(msg.line)
+			} else {
+				m.appendLogView(msg.line)
+			}
 		}
-		m.logMutex.Unlock()
 		return m, nil
 	}
 
@@ -442,117 +1736,468 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
+	if m.logViewOpen && !m.quitting {
+		return m.logPaneView()
+	}
+
 	var b strings.Builder
 
-	honey := honeyStyle.Render("🍯")
-	title := titleStyle.Render(fmt.Sprintf("\n%s HoneyRAG - Local RAG Stack %s", honey, honey))
+	honey := styles.honey.Render(glyphs.honey)
+	title := styles.title.Render(fmt.Sprintf("\n%s HoneyRAG - Local RAG Stack %s", honey, honey))
 	b.WriteString(title)
 	b.WriteString("\n\n")
 
+	if m.prior != nil && !m.quitting {
+		b.WriteString(m.statePrompt())
+		b.WriteString("\n\n")
+	}
+
 	for i, step := range m.steps {
 		var icon string
 		var status string
 
 		switch step.Status {
 		case "pending":
-			icon = dimStyle.Render("○")
-			status = dimStyle.Render(step.Description)
+			icon = styles.dim.Render(glyphs.pending)
+			status = styles.dim.Render(step.Description)
 		case "running":
 			icon = m.spinner.View()
-			status = waitingStyle.Render(step.Description + "...")
+			status = styles.waiting.Render(step.Description + "...")
+			if step.Total > 0 {
+				status += styles.waiting.Render(fmt.Sprintf(" (%s / %s)", formatBytes(step.Completed), formatBytes(step.Total)))
+			}
+			status += styles.dim.Render(fmt.Sprintf(" (%s)", formatElapsed(step.elapsed())))
+			if !step.Deadline.IsZero() {
+				left := max(time.Until(step.Deadline), 0).Round(time.Second)
+				status += styles.dim.Render(fmt.Sprintf(" (%s left)", left))
+			}
 		case "done":
-			icon = successStyle.Render("●")
-			status = successStyle.Render(step.Description)
+			icon = styles.success.Render(glyphs.done)
+			status = styles.success.Render(step.Description) + styles.dim.Render(fmt.Sprintf(" (%s)", formatElapsed(step.elapsed())))
+			if step.External {
+				icon = styles.skipped.Render(glyphs.skipped)
+				status = styles.skipped.Render(step.Description + " (skipped, already running)")
+			} else if step.Reused {
+				status = styles.success.Render(step.Description) + styles.dim.Render(" (already running)")
+			}
 		case "error":
-			icon = errorStyle.Render("✗")
-			status = errorStyle.Render(step.Description)
+			icon = styles.error.Render(glyphs.failed)
+			status = styles.error.Render(step.Description)
+		case "skipped":
+			icon = styles.skipped.Render(glyphs.skipped)
+			status = styles.skipped.Render(step.Description + " (skipped)")
 		}
 
-		line := fmt.Sprintf("  %s %s: %s", icon, step.Name, status)
+		cursor, name := "  ", step.Name
+		if i == m.selected {
+			cursor, name = styles.honey.Render(glyphs.cursor), styles.selected.Render(step.Name)
+		}
+		line := fmt.Sprintf("%s%s %s: %s", cursor, icon, name, status)
+		if m.done && step.Status == "done" && step.Service != "" {
+			line += m.usageView(step.Service)
+		}
 		b.WriteString(line)
 		b.WriteString("\n")
 
-		if i == 4 && (step.Status == "running" || step.Status == "done") {
-			b.WriteString(configStyle.Render(fmt.Sprintf("    Model: %s | GPU: %s | Context: %s",
-				m.config["model"], m.config["gpuUtil"], m.config["maxLen"])))
+		if i == m.selected && m.inspecting {
+			b.WriteString(m.stepDetails(i))
+			continue
+		}
+
+		if step.Status == "running" && step.Total > 0 {
+			b.WriteString(m.progressView(step))
 			b.WriteString("\n")
 		}
 
-		if len(step.LogLines) > 0 && step.Status == "running" {
-			for _, logLine := range step.LogLines {
-				truncated := logLine
-				if len(truncated) > 70 {
-					truncated = truncated[:70] + "..."
-				}
-				b.WriteString(logStyle.Render(fmt.Sprintf("    │ %s\n", truncated)))
-			}
+		if step.Extra != nil && (step.Status == "running" || step.Status == "done") {
+			b.WriteString(step.Extra(m))
+			b.WriteString("\n")
 		}
 
-		if step.Status == "running" && len(step.LogLines) == 0 {
-			hint := ""
-			switch i {
-			case 0:
-				hint = "installing dependencies..."
-			case 1:
-				hint = "checking installation..."
-			case 2:
-				hint = "waiting for server..."
-			case 3:
-				hint = "pulling model (~274MB)..."
-			case 4:
-				hint = "loading model to GPU..."
-			case 5:
-				hint = "initializing RAG..."
-			case 6:
-				hint = "starting web UI..."
-			}
-			if hint != "" {
-				b.WriteString(dimStyle.Render(fmt.Sprintf("    └─ %s\n", hint)))
+		if step.Status == "running" || (step.Status == "error" && (m.verbose || !step.DownSince.IsZero())) {
+			b.WriteString(m.logLinesView(step.LogLines))
+		}
+
+		if step.Status == "error" && !step.DownSince.IsZero() {
+			down := "unhealthy since " + step.DownSince.Format("15:04")
+			if step.Info != "" {
+				down += glyphs.sep + step.Info
 			}
+			b.WriteString(styles.error.Render(fmt.Sprintf("    %s %s\n", glyphs.branch, down)))
+		} else if step.Status == "running" && step.Info != "" {
+			b.WriteString(styles.waiting.Render(fmt.Sprintf("    %s %s\n", glyphs.branch, step.Info)))
+		} else if step.Status == "done" && step.Info != "" {
+			b.WriteString(styles.dim.Render(fmt.Sprintf("    %s %s\n", glyphs.branch, step.Info)))
+		} else if step.Status == "running" && len(step.LogLines) == 0 && step.Hint != "" {
+			b.WriteString(styles.dim.Render(fmt.Sprintf("    %s %s\n", glyphs.branch, step.Hint)))
 		}
 	}
 
 	b.WriteString("\n")
 
-	if m.err != nil {
-		b.WriteString(errorStyle.Render(fmt.Sprintf("Error: %v", m.err)))
+	if !m.startedAt.IsZero() && !m.quitting {
+		total := time.Since(m.startedAt)
+		if m.done {
+			total = m.finishedAt.Sub(m.startedAt)
+		}
+		b.WriteString(styles.dim.Render("  Total: " + formatElapsed(total)))
+		b.WriteString("\n\n")
+	}
+
+	if m.quitting {
+		b.WriteString(m.spinner.View() + " ")
+		b.WriteString(styles.waiting.Render("Stopping services..."))
+	} else if m.err != nil {
+		if m.errHint != nil {
+			b.WriteString(m.errHint.view())
+			b.WriteString("\n\n")
+		}
+		b.WriteString(styles.error.Render(fmt.Sprintf("Error: %v", m.err)))
 		b.WriteString("\n\n")
-		b.WriteString(dimStyle.Render("Check logs/ folder for details. Press 'q' to quit."))
+		b.WriteString(styles.dim.Render("Check logs/ folder for details. Press " + glyphs.updown + " and enter to inspect a step, 'l' for logs, 'o' to page the log file, 'r' to retry, 's' to skip or 'q' to quit."))
+		if m.monitoring {
+			b.WriteString("\n")
+			b.WriteString(styles.dim.Render(m.restartLegend()))
+		}
 	} else if m.done {
-		b.WriteString(successStyle.Render("✨ All services running!"))
+		b.WriteString(styles.success.Render(glyphs.sparkle + " All services running!"))
 		b.WriteString("\n\n")
-		b.WriteString(honeyStyle.Render("  🍯 Sweet endpoints ready:"))
+		b.WriteString(styles.honey.Render("  " + glyphs.honey + " Sweet endpoints ready:"))
 		b.WriteString("\n\n")
-		b.WriteString(fmt.Sprintf("     Agent UI:     %s\n", urlStyle.Render(fmt.Sprintf("http://localhost:%s", m.ports["agno"]))))
-		b.WriteString(fmt.Sprintf("     LightRAG UI:  %s\n", urlStyle.Render(fmt.Sprintf("http://localhost:%s", m.ports["lightrag"]))))
-		b.WriteString(fmt.Sprintf("     vLLM API:     %s\n", urlStyle.Render(fmt.Sprintf("http://localhost:%s", m.ports["vllm"]))))
+		for i, e := range m.endpoints() {
+			key := " "
+			if i < 9 {
+				key = strconv.Itoa(i + 1)
+			}
+			b.WriteString(fmt.Sprintf("  %s  %-14s%s\n", styles.dim.Render(key), e.label+":", styles.url.Render(e.url)))
+		}
 		b.WriteString("\n")
-		b.WriteString(dimStyle.Render("  Logs: logs/ | Press 'q' to stop all services"))
+		for _, e := range m.exposed() {
+			b.WriteString(styles.skipped.Render("  " + glyphs.warning + " " + e + ": reachable from other machines"))
+			b.WriteString("\n")
+		}
+		for _, move := range m.portMoves() {
+			b.WriteString(styles.skipped.Render("  " + glyphs.warning + " " + move))
+			b.WriteString("\n")
+		}
+		b.WriteString(styles.dim.Render("  Logs: logs/ | Step timings: logs/" + summaryFile))
+		b.WriteString("\n\n")
+		if m.confirmQuit {
+			b.WriteString(styles.waiting.Render("  Stop all services? [y/N]"))
+		} else {
+			b.WriteString(styles.dim.Render("  " + m.doneLegend()))
+		}
 	} else {
-		b.WriteString(dimStyle.Render("  Setting up... Press 'q' to cancel"))
+		b.WriteString(styles.dim.Render("  Setting up... Press " + glyphs.updown + " and enter to inspect a step, 'l' for logs, 'o' to page the log file, 'q' to cancel"))
 	}
 
 	b.WriteString("\n")
+	switch m.keyPrefix {
+	case "R":
+		b.WriteString(styles.waiting.Render("  Restart which service? Press its step number; any other key cancels."))
+		b.WriteString("\n")
+	case "c":
+		b.WriteString(styles.waiting.Render("  Copy which endpoint? Press its number; any other key cancels."))
+		b.WriteString("\n")
+	}
+	if m.notice != "" {
+		b.WriteString(styles.error.Render("  " + m.notice))
+		b.WriteString("\n")
+	}
 
 	return b.String()
 }
 
+// verboseLogLines is how much of a step's output --verbose keeps on screen.
+const verboseLogLines = 20
+
+// logLinesKept is how many of a step's latest log lines are kept to show
+// under it.
+func (m Model) logLinesKept() int {
+	if m.verbose {
+		return verboseLogLines
+	}
+	return 3
+}
+
+// logLinesView renders log lines under a step: cut to the terminal width,
+// or with --verbose wrapped onto as many rows as they take.
+func (m Model) logLinesView(lines []string) string {
+	var b strings.Builder
+	width := m.logLineWidth()
+	for _, line := range lines {
+		if !m.verbose {
+			b.WriteString(styles.log.Render(fmt.Sprintf("    %s %s\n", glyphs.bar, truncate(line, width))))
+			continue
+		}
+		for _, row := range wrap(line, width) {
+			b.WriteString(styles.log.Render(fmt.Sprintf("    %s %s\n", glyphs.bar, row)))
+		}
+	}
+	return b.String()
+}
+
+// progressView renders a bar for a step downloading something, as wide as
+// its log lines but no wider than 60 columns.
+func (m Model) progressView(step Step) string {
+	bar := m.progress
+	bar.Width = min(m.logLineWidth(), 60)
+	return "    " + bar.ViewAs(float64(step.Completed)/float64(step.Total))
+}
+
+// logLineWidth is how many characters of a log line fit next to the
+// "    │ " prefix, falling back to 70 before the terminal size is known.
+func (m Model) logLineWidth() int {
+	if m.width <= 0 {
+		return 70
+	}
+	return max(m.width-10, 10)
+}
+
+// formatBytes renders n using binary units, e.g. "274.3 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// wrap splits s into rows of at most width characters.
+func wrap(s string, width int) []string {
+	runes := []rune(s)
+	rows := []string{}
+	for len(runes) > width {
+		rows = append(rows, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(rows, string(runes))
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width]) + "..."
+}
+
 func main() {
-	baseDir, err := os.Getwd()
+	nonInteractive := flag.Bool("non-interactive", false, "run the pipeline with plain output instead of the TUI")
+	flag.BoolVar(nonInteractive, "ci", false, "alias for --non-interactive")
+	flag.BoolVar(nonInteractive, "no-tui", false, "alias for --non-interactive")
+	skipDeps := flag.Bool("skip-deps", false, "skip the Python Deps step (uv sync)")
+	skipOllamaInstall := flag.Bool("skip-ollama-install", false, "skip checking for and installing Ollama")
+	jsonStream := flag.Bool("json", false, "print step transitions as JSON lines instead of showing the TUI")
+	output := flag.String("output", "tui", "`format` to report progress in: tui, or json for one JSON event per line on stdout (step_started, step_log, step_done, step_error, stack_ready)")
+	assumeYes := flag.Bool("yes", false, "install uv and Ollama and download VLLM_MODEL if needed, without asking")
+	baseDirFlag := flag.String("base-dir", "", "`directory` of the honeyrag checkout (default: HONEYRAG_DIR, or the current directory or a parent with pyproject.toml)")
+	verbose := flag.Bool("verbose", false, "show each running or failed step's recent output in full instead of its last 3 lines, cut to fit")
+	flag.BoolVar(verbose, "v", false, "alias for --verbose")
+	dryRun := flag.Bool("dry-run", false, "print the commands each step would run, without running them")
+	expose := flag.Bool("expose", false, "make the agent, LightRAG and vLLM reachable from other machines (listen on 0.0.0.0)")
+	debugEnv := flag.Bool("debug-env", false, "write the environment each service is started with, secrets hidden, at the top of its log")
+	forceRestart := flag.Bool("force-restart", false, "stop services left running by an earlier honeyrag and start them again")
+	only := flag.String("only", "", "comma-separated `services` to start, with the steps they need (ollama, vllm, lightrag, agent)")
+	without := flag.String("without", "", "comma-separated `services` not to start")
+	configure := flag.Bool("configure", false, "set the model, ports and GPU memory share in a form before starting, as on the first run")
+	ascii := flag.Bool("ascii", false, "draw the TUI with plain ASCII instead of symbols and emoji (the default when the locale isn't UTF-8)")
+	plain := flag.Bool("plain", false, "draw the TUI without colors and in plain ASCII (colors are also left out when NO_COLOR is set)")
+	timeout := flag.Duration("timeout", 0, "give up, stopping everything, if the stack isn't up this long after the first step starts, e.g. 20m (default: no limit)")
+	skip := flag.String("skip", "", "comma-separated `services` already running elsewhere: only check that they answer")
+	portFlags := make(map[string]*string)
+	for _, svc := range services {
+		portFlags[svc.name] = flag.String(svc.name+"-port", "",
+			fmt.Sprintf("`port` for %s (overrides %s, default %s)", svc.label, svc.portEnv, svc.defaultPort))
+	}
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: honeyrag [flags] [stop [service...] | status [--json] | logs [service...] [-f] [--lines N] | config show | query [--mode M] [--json] \"question\"]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	setDisplay(*ascii, *plain)
+
+	switch *output {
+	case "tui":
+	case "json":
+		*jsonStream = true
+	default:
+		fmt.Printf("Error: --output must be tui or json, not %q\n", *output)
+		os.Exit(2)
+	}
+
+	portOverrides := make(map[string]string)
+	for _, svc := range services {
+		port := *portFlags[svc.name]
+		if port == "" {
+			continue
+		}
+		if err := validatePort(port); err != nil {
+			fmt.Printf("Error: --%s-port: %v\n", svc.name, err)
+			os.Exit(2)
+		}
+		portOverrides[svc.portKey] = port
+	}
+
+	// Without a terminal to draw on or read keys from (a script, cron, a
+	// pipe), nobody could answer the TUI, so run headless.
+	if !*jsonStream && !*dryRun && (!isTerminal(os.Stdin) || !isTerminal(os.Stdout)) {
+		*nonInteractive = true
+	}
+
+	baseDir, err := findBaseDir(*baseDirFlag)
 	if err != nil {
-		fmt.Println("Error getting current directory:", err)
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 
-	if _, err := os.Stat(filepath.Join(baseDir, "pyproject.toml")); os.IsNotExist(err) {
-		fmt.Println("Error: Run this from the honeyrag directory")
-		fmt.Println("Expected to find: pyproject.toml")
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "stop":
+			// A broken configs/.env shouldn't get in the way of stopping.
+			var ports, hosts map[string]string
+			if model, err := initialModel(baseDir, portOverrides); err == nil {
+				ports, hosts = model.ports, make(map[string]string)
+				for _, svc := range services {
+					hosts[svc.portKey] = model.bindHost(svc.portKey)
+				}
+			}
+			os.Exit(runStop(filepath.Join(baseDir, "logs"), ports, hosts, flag.Args()[1:]))
+		case "logs":
+			os.Exit(runLogs(filepath.Join(baseDir, "logs"), flag.Args()[1:]))
+		case "config":
+			model, err := initialModel(baseDir, portOverrides)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(2)
+			}
+			os.Exit(runConfig(model, flag.Args()[1:]))
+		case "status":
+			model, err := initialModel(baseDir, portOverrides)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(2)
+			}
+			os.Exit(runStatus(model, flag.Args()[1:]))
+		case "query":
+			model, err := initialModel(baseDir, portOverrides)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(2)
+			}
+			os.Exit(runQuery(model, flag.Args()[1:]))
+		default:
+			fmt.Printf("Error: unknown command %q\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+
+	if !*nonInteractive && !*jsonStream && !*dryRun {
+		setup, err := needsSetup(baseDir, *configure)
+		if err == nil && setup {
+			err = runSetup(baseDir)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+
+	model, err := initialModel(baseDir, portOverrides)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	for i, step := range model.steps {
+		if (step.Key == "deps" && *skipDeps) || (step.Key == "ollama-install" && *skipOllamaInstall) {
+			model.steps[i].Status = "skipped"
+		}
+	}
+	if model.steps, err = selectSteps(model.steps, *only, *without, *skip); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	if err := model.checkCheckout(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if err := model.checkPlatform(); err != nil {
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+	model.expose = *expose
+	model.debugEnv = *debugEnv
+	if *timeout < 0 {
+		fmt.Println("Error: --timeout must not be negative")
+		os.Exit(2)
+	}
+	model.runTimeout = *timeout
+	if *dryRun {
+		// Keep the run log for real runs.
+		model.dryRun, model.events, model.processes.events = true, nil, nil
+		os.Exit(runHeadless(model))
+	}
+
+	model.forceRestart = *forceRestart
+	model.verbose = *verbose
+	model.consent = consent{uvInstall: *assumeYes, pythonInstall: *assumeYes, ollamaInstall: *assumeYes, modelDownload: *assumeYes, restart: *forceRestart}
+	if !*assumeYes && !*nonInteractive && !*jsonStream {
+		if model.hasStep("tools") {
+			model.consent.uvInstall = confirmUVInstall()
+		}
+		if model.hasStep("deps") {
+			model.consent.pythonInstall = model.confirmPythonInstall()
+		}
+		if model.hasStep("ollama-install") {
+			model.consent.ollamaInstall = model.confirmOllamaInstall()
+		}
+		if model.hasStep("vllm") {
+			model.consent.modelDownload = model.confirmModelDownload()
+		}
+	}
+	if !*forceRestart && !*nonInteractive && !*jsonStream {
+		model.consent.restart = model.confirmRestart()
+	}
+
+	if *nonInteractive {
+		os.Exit(runHeadless(model))
+	}
+
+	var opts []tea.ProgramOption
+	if *jsonStream {
+		// Same Update loop, no screen and no keyboard; SIGINT/SIGTERM quit.
+		model.jsonStream = true
+		model.jsonEvents = *output == "json"
+		model.prior = nil
+		opts = append(opts, tea.WithoutRenderer(), tea.WithInput(nil))
+	}
+	opts = append(opts, tea.WithoutSignalHandler())
+	p := tea.NewProgram(model, opts...)
+	model.notifier.attach(p.Send)
+	stopSignals := forwardSignals(p)
+	final, err := p.Run()
+	stopSignals()
+
+	// Normally the TUI has already stopped everything on quit; this covers
+	// the program exiting any other way.
+	model.processes.stopAll(5 * time.Second)
 
-	p := tea.NewProgram(initialModel(baseDir))
-	if _, err := p.Run(); err != nil {
+	if err != nil {
 		fmt.Printf("Error: %v", err)
 		os.Exit(1)
 	}
+	// Quitting after a failure, from the TUI or in --json mode, exits with
+	// the failure's code, as headless mode does.
+	if m, ok := final.(Model); ok {
+		if i := m.failedStep(); i >= 0 {
+			os.Exit(m.failureExitCode(i, m.err))
+		}
+	}
 }
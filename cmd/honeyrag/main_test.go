@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// testModel is a Model over steps with the state a run needs and nothing
+// read from the environment; logs go to a temporary directory.
+func testModel(t *testing.T, steps []Step) Model {
+	t.Helper()
+	baseDir := t.TempDir()
+	logsDir := filepath.Join(baseDir, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ports := make(map[string]string)
+	for _, svc := range services {
+		ports[svc.portKey] = svc.defaultPort
+	}
+	s := spinner.New()
+	s.Spinner = glyphs.spinner
+	s.Style = styles.spinner
+	m := Model{
+		steps:          steps,
+		spinner:        s,
+		progress:       newProgressBar(),
+		baseDir:        baseDir,
+		logsDir:        logsDir,
+		ports:          ports,
+		config:         map[string]string{"llmBackend": llmBackendVLLM},
+		healthFailures: make(map[int]int),
+		restarts:       make(map[int]int),
+		selected:       -1,
+		runtimeState:   newRuntimeState(logsDir),
+	}
+	t.Cleanup(func() { m.cancel() })
+	return m
+}
+
+// testStep is a pending step named after key that runs run.
+func testStep(key string, run func(ctx context.Context) error, dependsOn ...string) Step {
+	return Step{
+		Name: key, Key: key, Description: "Run " + key, Status: "pending", DependsOn: dependsOn,
+		Run: func(m Model, ctx context.Context, index int) error { return run(ctx) },
+	}
+}
+
+// runPipeline drives m the way Bubble Tea would from the start of the
+// pipeline: each command runs on its own goroutine and every message it
+// produces goes through Update, until the run is done or has failed and no
+// step is still running. Ticks scheduled after that are dropped.
+func runPipeline(t *testing.T, m Model) Model {
+	t.Helper()
+	return runFrom(t, m, func() tea.Msg { return pipelineStartMsg{} })
+}
+
+// runFrom is runPipeline starting with the command cmd instead.
+func runFrom(t *testing.T, m Model, cmd tea.Cmd) Model {
+	t.Helper()
+	msgs := make(chan tea.Msg, 256)
+	run := func(cmd tea.Cmd) {
+		if cmd != nil {
+			go func() { msgs <- cmd() }()
+		}
+	}
+	// Steps report log lines through the notifier, as to the program.
+	m.notifier.attach(func(msg tea.Msg) { msgs <- msg })
+	defer m.notifier.attach(nil)
+	settled := func() bool {
+		if !m.done && m.err == nil {
+			return false
+		}
+		return !slices.ContainsFunc(m.steps, func(s Step) bool { return s.Status == "running" })
+	}
+
+	run(cmd)
+	timeout := time.After(10 * time.Second)
+	for !settled() {
+		select {
+		case msg := <-msgs:
+			if batch, ok := msg.(tea.BatchMsg); ok {
+				for _, cmd := range batch {
+					run(cmd)
+				}
+				continue
+			}
+			next, cmd := m.Update(msg)
+			m = next.(Model)
+			run(cmd)
+		case <-timeout:
+			t.Fatalf("pipeline still running after 10s: %v", stepStatuses(m))
+		}
+	}
+	return m
+}
+
+func stepStatuses(m Model) map[string]string {
+	statuses := make(map[string]string)
+	for _, step := range m.steps {
+		statuses[step.Key] = step.Status
+	}
+	return statuses
+}
+
+// recorder notes the order steps start and finish in.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) note(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// step returns a Run body that records key starting, runs body and records
+// it finishing.
+func (r *recorder) step(key string, body func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.note("start " + key)
+		err := body(ctx)
+		r.note("end " + key)
+		return err
+	}
+}
+
+func (r *recorder) index(event string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Index(r.events, event)
+}
+
+func succeed(ctx context.Context) error { return nil }
+
+// rendezvous returns a Run body for each of n steps that only returns once
+// all n are running at the same time, and fails if they never are.
+func rendezvous(n int) func(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	all := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(all)
+	}()
+	return func(ctx context.Context) error {
+		wg.Done()
+		select {
+		case <-all:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("the other steps never ran alongside this one")
+		}
+	}
+}
+
+func TestPipelineOrderAndConcurrency(t *testing.T) {
+	r := &recorder{}
+	together := rendezvous(2)
+	m := testModel(t, []Step{
+		testStep("deps", r.step("deps", together)),
+		testStep("ollama", r.step("ollama", together)),
+		testStep("lightrag", r.step("lightrag", succeed), "deps", "ollama"),
+		testStep("agent", r.step("agent", succeed), "lightrag"),
+	})
+
+	m = runPipeline(t, m)
+
+	if m.err != nil {
+		t.Fatalf("pipeline failed: %v", m.err)
+	}
+	if !m.done {
+		t.Fatalf("pipeline not done: %v", stepStatuses(m))
+	}
+	for key, status := range stepStatuses(m) {
+		if status != "done" {
+			t.Errorf("step %s is %s, want done", key, status)
+		}
+	}
+	for _, order := range [][2]string{
+		{"end deps", "start lightrag"},
+		{"end ollama", "start lightrag"},
+		{"end lightrag", "start agent"},
+	} {
+		before, after := r.index(order[0]), r.index(order[1])
+		if before < 0 || after < 0 || before > after {
+			t.Errorf("want %q before %q, got %v", order[0], order[1], r.events)
+		}
+	}
+	if m.startedAt.IsZero() || m.finishedAt.Before(m.startedAt) {
+		t.Errorf("pipeline timed from %v to %v", m.startedAt, m.finishedAt)
+	}
+}
+
+func TestPipelineSkippedDependency(t *testing.T) {
+	r := &recorder{}
+	steps := []Step{
+		testStep("ollama", r.step("ollama", succeed)),
+		testStep("embedding", r.step("embedding", succeed), "ollama"),
+	}
+	steps[0].Status = "skipped"
+
+	m := runPipeline(t, testModel(t, steps))
+
+	if !m.done || m.err != nil {
+		t.Fatalf("done = %v, err = %v, want a finished pipeline", m.done, m.err)
+	}
+	if r.index("start ollama") >= 0 {
+		t.Error("the skipped step ran")
+	}
+	if m.steps[1].Status != "done" {
+		t.Errorf("embedding is %s, want done once its skipped dependency is", m.steps[1].Status)
+	}
+}
+
+func TestPipelineFailure(t *testing.T) {
+	r := &recorder{}
+	boom := errors.New("lightrag exited with status 1")
+	m := testModel(t, []Step{
+		testStep("deps", r.step("deps", succeed)),
+		testStep("ollama", r.step("ollama", succeed)),
+		testStep("lightrag", r.step("lightrag", func(ctx context.Context) error { return boom }), "deps", "ollama"),
+		testStep("agent", r.step("agent", succeed), "lightrag"),
+		testStep("docs", r.step("docs", succeed), "lightrag"),
+	})
+
+	m = runPipeline(t, m)
+
+	if !errors.Is(m.err, boom) {
+		t.Fatalf("err = %v, want the failed step's error", m.err)
+	}
+	if m.done {
+		t.Error("done is set after a failure")
+	}
+	want := map[string]string{"deps": "done", "ollama": "done", "lightrag": "error", "agent": "pending", "docs": "pending"}
+	if got := stepStatuses(m); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if r.index("start agent") >= 0 || r.index("start docs") >= 0 {
+		t.Errorf("steps after the failure ran: %v", r.events)
+	}
+	if m.steps[2].FinishedAt.IsZero() {
+		t.Error("the failed step has no FinishedAt")
+	}
+
+	// A step finishing after the failure doesn't start any more.
+	next, cmd := m.Update(stepDoneMsg{index: 2})
+	if cmd != nil || next.(Model).steps[3].Status != "pending" {
+		t.Error("a late stepDoneMsg for the failed step dispatched more steps")
+	}
+
+	var report failureReport
+	data, err := os.ReadFile(filepath.Join(m.logsDir, failureFile))
+	if err != nil {
+		t.Fatalf("no failure report: %v", err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Key != "lightrag" || report.Number != 3 || report.Error != boom.Error() {
+		t.Errorf("failure report names step %q (#%d) with %q", report.Key, report.Number, report.Error)
+	}
+}
+
+func TestPipelineFailureCancelsSiblings(t *testing.T) {
+	boom := errors.New("vllm exited with status 1")
+	started := make(chan struct{})
+	var ollamaRuns, vllmRuns atomic.Int32
+	m := testModel(t, []Step{
+		testStep("ollama", func(ctx context.Context) error {
+			if ollamaRuns.Add(1) == 1 {
+				// A long health wait, which only the failure ends.
+				close(started)
+				<-ctx.Done()
+			}
+			return ctx.Err()
+		}),
+		testStep("vllm", func(ctx context.Context) error {
+			if vllmRuns.Add(1) == 1 {
+				<-started
+				return boom
+			}
+			return ctx.Err()
+		}),
+		testStep("agent", succeed, "ollama", "vllm"),
+	})
+
+	m = runPipeline(t, m)
+
+	if !errors.Is(m.err, boom) {
+		t.Fatalf("err = %v, want vllm's error, not the cancelled sibling's", m.err)
+	}
+	want := map[string]string{"ollama": "pending", "vllm": "error", "agent": "pending"}
+	if got := stepStatuses(m); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+
+	// Retrying runs both again, with a context that isn't cancelled.
+	m, cmd := press(m, "r")
+	m = runFrom(t, m, cmd)
+	if !m.done {
+		t.Fatalf("retry failed: %v (%v)", m.err, stepStatuses(m))
+	}
+	if ollamaRuns.Load() != 2 || vllmRuns.Load() != 2 {
+		t.Errorf("ollama ran %d times and vllm %d, want twice each", ollamaRuns.Load(), vllmRuns.Load())
+	}
+}
+
+// press sends key to m as Bubble Tea would.
+func press(m Model, key string) (Model, tea.Cmd) {
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	return next.(Model), cmd
+}
+
+func TestDoneScreenKeys(t *testing.T) {
+	m := testModel(t, []Step{testStep("tools", succeed), testStep("lightrag", succeed)})
+	m.steps[1].Service = "lightrag"
+	for i := range m.steps {
+		m.steps[i].Status = "done"
+	}
+	m.done = true
+
+	// A number opens the endpoint listed under it and restarts nothing.
+	m, cmd := press(m, "1")
+	if cmd == nil || m.steps[1].Status != "done" {
+		t.Errorf("'1' gave command %v and lightrag %s, want the browser opened and lightrag left done", cmd, m.steps[1].Status)
+	}
+	if m, cmd = press(m, "2"); cmd != nil {
+		t.Error("'2' did something with only one endpoint listed")
+	}
+
+	// 'R' and the step number restart the service.
+	m, _ = press(m, "R")
+	if m.keyPrefix != "R" || !strings.Contains(m.View(), "Restart which service?") {
+		t.Fatalf("'R' left keyPrefix %q, want it waiting for a step number", m.keyPrefix)
+	}
+	if m, cmd = press(m, "1"); cmd != nil || m.keyPrefix != "" {
+		t.Error("'R' then '1' restarted tools, which starts no service")
+	}
+	m, _ = press(m, "R")
+	if m, cmd = press(m, "2"); cmd == nil || m.steps[1].Status != "running" {
+		t.Errorf("'R' then '2' left lightrag %s, want it restarting", m.steps[1].Status)
+	}
+}
+
+func TestBuildStepsDependencies(t *testing.T) {
+	for _, backend := range []string{llmBackendVLLM, llmBackendOllama, llmBackendRemote} {
+		for _, embedding := range []string{embeddingBackendOllama, embeddingBackendVLLM} {
+			config := map[string]string{"llmBackend": backend, "embeddingBackend": embedding, "docsDir": "docs"}
+			steps := buildSteps(config)
+			keys := make(map[string]int)
+			for i, step := range steps {
+				keys[step.Key] = i
+			}
+			for i, step := range steps {
+				if step.Run == nil {
+					t.Errorf("%s/%s: step %s has no Run", backend, embedding, step.Key)
+				}
+				for _, dep := range step.DependsOn {
+					j, ok := keys[dep]
+					if !ok {
+						t.Errorf("%s/%s: step %s depends on %s, which isn't a step", backend, embedding, step.Key, dep)
+					} else if j >= i {
+						t.Errorf("%s/%s: step %s depends on %s, which comes after it", backend, embedding, step.Key, dep)
+					}
+				}
+			}
+		}
+	}
+}
+
+// lastLinesOf is what readLastLines should return for a file holding
+// content, worked out the slow way: split it all and keep the last n lines.
+func lastLinesOf(content string, n int) string {
+	if n <= 0 || content == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	lines = lines[max(len(lines)-n, 0):]
+	for i, line := range lines {
+		text := strings.TrimSuffix(line[:min(len(line), tailMaxLine)], "\r")
+		if len(line) > tailMaxLine {
+			text = strings.ToValidUTF8(text, "") + fmt.Sprintf("... (%s)", formatBytes(int64(len(line))))
+		}
+		lines[i] = text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// numberedLines is count lines of varying length, the longest 997 bytes.
+func numberedLines(count int) string {
+	var b strings.Builder
+	for i := range count {
+		fmt.Fprintf(&b, "line %07d %s\n", i, strings.Repeat("x", i*7919%985))
+	}
+	return b.String()
+}
+
+func TestReadLastLines(t *testing.T) {
+	long := strings.Repeat("v", tailMaxLine+10)
+	huge := strings.Repeat("w", 3*tailChunkSize+123)
+	// A line that starts just before the last chunk and ends inside it,
+	// and files whose newline falls on the first and the last byte of a
+	// chunk.
+	straddling := numberedLines(100) + strings.Repeat("s", 1000) + "\n" + strings.Repeat("t", tailChunkSize-10) + "\n"
+	onChunkStart := numberedLines(100) + strings.Repeat("a", tailChunkSize-1)
+	onChunkEnd := numberedLines(100) + strings.Repeat("b", tailChunkSize)
+	big := numberedLines(10000)
+
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    string
+	}{
+		{name: "empty file", content: "", n: 5, want: ""},
+		{name: "only a newline", content: "\n", n: 5, want: ""},
+		{name: "fewer lines than asked", content: "a\nb\n", n: 5, want: "a\nb"},
+		{name: "last lines", content: "a\nb\nc\nd\n", n: 2, want: "c\nd"},
+		{name: "no trailing newline", content: "a\nb\nc", n: 2, want: "b\nc"},
+		{name: "single line without newline", content: "only", n: 3, want: "only"},
+		{name: "blank lines kept", content: "a\n\n\nb\n", n: 3, want: "\n\nb"},
+		{name: "blank last line", content: "a\n\n", n: 1, want: ""},
+		{name: "crlf", content: "a\r\nb\r\n", n: 2, want: "a\nb"},
+		{name: "n zero", content: "a\nb\n", n: 0, want: ""},
+		{name: "line over tailMaxLine", content: "a\n" + long + "\nz\n", n: 2,
+			want: strings.Repeat("v", tailMaxLine) + "... (4.0 KB)\nz"},
+		{name: "line over tailMaxLine without newline", content: "a\n" + long, n: 1,
+			want: strings.Repeat("v", tailMaxLine) + "... (4.0 KB)"},
+		{name: "line over several chunks", content: "a\nb\n" + huge + "\nc\n", n: 3, want: lastLinesOf("a\nb\n"+huge+"\nc\n", 3)},
+		{name: "line over several chunks, last", content: "a\n" + huge, n: 2, want: lastLinesOf("a\n"+huge, 2)},
+		{name: "line across a chunk boundary", content: straddling, n: 3, want: lastLinesOf(straddling, 3)},
+		{name: "newline at a chunk's first byte", content: onChunkStart, n: 2, want: lastLinesOf(onChunkStart, 2)},
+		{name: "newline at a chunk's last byte", content: onChunkEnd, n: 2, want: lastLinesOf(onChunkEnd, 2)},
+		{name: "multi-MB, last lines", content: big, n: 20, want: lastLinesOf(big, 20)},
+		{name: "multi-MB, many chunks back", content: big, n: 1000, want: lastLinesOf(big, 1000)},
+		{name: "multi-MB, whole file", content: big, n: 20000, want: strings.TrimSuffix(big, "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.log")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := readLastLines(path, tt.n); got != tt.want {
+				t.Errorf("readLastLines(%d) of %d bytes = %.200q (%d bytes), want %.200q (%d bytes)",
+					tt.n, len(tt.content), got, len(got), tt.want, len(tt.want))
+			}
+		})
+	}
+	if len(big) < 2<<20 {
+		t.Errorf("multi-MB cases only use %d bytes", len(big))
+	}
+}
+
+func TestReadLastLinesMissingFile(t *testing.T) {
+	got := readLastLines(filepath.Join(t.TempDir(), "missing.log"), 5)
+	if !strings.HasPrefix(got, "(could not read log: ") {
+		t.Errorf("readLastLines of a missing file = %q", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultHealthInterval is how often running services are checked once the
+// pipeline is done, unless HONEYRAG_HEALTH_INTERVAL says otherwise.
+const defaultHealthInterval = 15 * time.Second
+
+// defaultMaxRestarts caps how many times HONEYRAG_AUTO_RESTART restarts a
+// service in one session, unless HONEYRAG_MAX_RESTARTS says otherwise.
+const defaultMaxRestarts = 3
+
+// autoRestartDelay is the wait before the first automatic restart; it
+// doubles with every restart after that.
+const autoRestartDelay = 5 * time.Second
+
+// supervision is how services are watched once the pipeline is done.
+type supervision struct {
+	interval    time.Duration
+	autoRestart bool
+	maxRestarts int
+}
+
+// loadSupervision reads HONEYRAG_HEALTH_INTERVAL, HONEYRAG_AUTO_RESTART and
+// HONEYRAG_MAX_RESTARTS.
+func loadSupervision() (supervision, error) {
+	s := supervision{interval: defaultHealthInterval, maxRestarts: defaultMaxRestarts}
+	if value, ok := os.LookupEnv("HONEYRAG_HEALTH_INTERVAL"); ok {
+		d, err := parseTimeout(value)
+		if err != nil {
+			return s, fmt.Errorf("invalid HONEYRAG_HEALTH_INTERVAL=%q: %v", value, err)
+		}
+		s.interval = d
+	}
+	if value := os.Getenv("HONEYRAG_AUTO_RESTART"); value != "" {
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return s, fmt.Errorf("invalid HONEYRAG_AUTO_RESTART=%q: expected true or false", value)
+		}
+		s.autoRestart = on
+	}
+	if value, ok := os.LookupEnv("HONEYRAG_MAX_RESTARTS"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return s, fmt.Errorf("invalid HONEYRAG_MAX_RESTARTS=%q: expected a number of restarts", value)
+		}
+		s.maxRestarts = n
+	}
+	return s, nil
+}
+
+// healthFailureLimit is how many checks in a row a service must fail before
+// it is marked as down, so one slow answer doesn't flip it.
+const healthFailureLimit = 2
+
+type healthTickMsg struct{}
+
+// autoRestartMsg asks for service step index to be restarted after it went
+// down; attempt counts from 1.
+type autoRestartMsg struct {
+	index   int
+	attempt int
+}
+
+// healthResultMsg carries whether each checked service step answered.
+type healthResultMsg struct {
+	healthy map[int]bool
+}
+
+func (m Model) healthTick() tea.Cmd {
+	return tea.Tick(m.supervision.interval, func(time.Time) tea.Msg { return healthTickMsg{} })
+}
+
+// checkHealth health-checks every service step that is done.
+func (m Model) checkHealth() tea.Cmd {
+	services := make(map[int]string)
+	for i, step := range m.steps {
+		if step.Service != "" && step.Status == "done" {
+			services[i] = m.serviceKey(i)
+		}
+	}
+	parent := m.ctx
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(parent, m.supervision.interval)
+		defer cancel()
+		healthy := make(map[int]bool, len(services))
+		for i, key := range services {
+			healthy[i] = m.verifyService(ctx, key)
+		}
+		return healthResultMsg{healthy: healthy}
+	}
+}
+
+// serviceKey returns the Model.ports key of the service step index starts.
+func (m Model) serviceKey(index int) string {
+	if svc, ok := lookupService(m.steps[index].Service); ok {
+		return svc.portKey
+	}
+	return m.steps[index].Service
+}
+
+// applyHealth marks services that have stopped answering as failed, so a
+// service dying after startup shows up in red with the end of its log and
+// can be restarted with 'R' and its number or retried with 'r'. With
+// HONEYRAG_AUTO_RESTART it also schedules a restart, backing off each time,
+// until the service has used up its restarts.
+func (m *Model) applyHealth(msg healthResultMsg) tea.Cmd {
+	var cmds []tea.Cmd
+	for i, ok := range msg.healthy {
+		if ok || m.steps[i].Status != "done" {
+			m.healthFailures[i] = 0
+			continue
+		}
+		m.healthFailures[i]++
+		if m.healthFailures[i] < healthFailureLimit {
+			continue
+		}
+		m.healthFailures[i] = 0
+		now := time.Now()
+		err := fmt.Errorf("%s stopped responding at %s", m.steps[i].Name, now.Format("15:04:05"))
+		step := &m.steps[i]
+		step.Status = "error"
+		step.FinishedAt = now
+		step.DownSince = now
+		step.Info = ""
+		step.LogLines = nil
+		if tail := readLastLines(m.stepLogPath(i), 3); tail != "" && step.LogFile != "" {
+			step.LogLines = strings.Split(tail, "\n")
+		}
+		m.logStep(i, "failed: %s", err)
+		m.emitStep(i, err)
+		m.err = err
+		m.done = false
+
+		cmds = append(cmds, m.scheduleRestart(i))
+	}
+	return tea.Batch(cmds...)
+}
+
+// scheduleRestart arranges the next automatic restart of service step
+// index, which is down, if HONEYRAG_AUTO_RESTART is on and it has restarts
+// left.
+func (m *Model) scheduleRestart(index int) tea.Cmd {
+	if !m.supervision.autoRestart {
+		return nil
+	}
+	attempt := m.restarts[index] + 1
+	if attempt > m.supervision.maxRestarts {
+		m.steps[index].Info = fmt.Sprintf("gave up after %d automatic restarts", m.supervision.maxRestarts)
+		return nil
+	}
+	m.restarts[index] = attempt
+	delay := autoRestartDelay << (attempt - 1)
+	m.steps[index].Info = fmt.Sprintf("restart %d/%d in %s", attempt, m.supervision.maxRestarts, delay)
+	return tea.Tick(delay, func(time.Time) tea.Msg { return autoRestartMsg{index: index, attempt: attempt} })
+}
+
+// autoRestart restarts service step index after it went down, unless the
+// user has dealt with it in the meantime, and notes the restart in the
+// service's log.
+func (m *Model) autoRestart(msg autoRestartMsg) tea.Cmd {
+	i := msg.index
+	if m.quitting || m.steps[i].Status != "error" || m.steps[i].DownSince.IsZero() {
+		return nil
+	}
+	note := fmt.Sprintf("automatic restart %d/%d after %s stopped responding at %s",
+		msg.attempt, m.supervision.maxRestarts, m.steps[i].Name, m.steps[i].DownSince.Format("15:04:05"))
+	if m.steps[i].LogFile != "" {
+		appendLogNote(m.stepLogPath(i), note)
+	}
+	m.logStep(i, "%s", note)
+	if m.otherErrors(i) == 0 {
+		m.err = nil
+	}
+	down := m.steps[i].DownSince
+	m.startStep(i)
+	// Kept so that a restart that fails is retried too.
+	m.steps[i].DownSince = down
+	m.steps[i].Deadline = time.Time{}
+	m.emitStep(i, nil)
+	return m.restartStep(i)
+}
+
+// otherErrors counts the failed steps other than index.
+func (m Model) otherErrors(index int) int {
+	n := 0
+	for i, step := range m.steps {
+		if i != index && step.Status == "error" {
+			n++
+		}
+	}
+	return n
+}
+
+// appendLogNote adds a line from honeyrag itself to a service's log, in the
+// style of the session banners.
+func appendLogNote(path, note string) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "\n==== %s: %s ====\n", time.Now().Format("2006-01-02 15:04:05"), note)
+}
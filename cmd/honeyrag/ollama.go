@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ollamaEndpoint splits OLLAMA_HOST into scheme, host and port the way the
+// ollama CLI reads it; see serviceEndpoint.
+func (m Model) ollamaEndpoint() (scheme, host, port string) {
+	return m.serviceEndpoint("ollama")
+}
+
+// ollamaURL returns the URL of path on the Ollama server.
+func (m Model) ollamaURL(path string) string {
+	return m.serviceURL("ollama", path)
+}
+
+// ollamaRemote reports whether OLLAMA_HOST points at another machine, in
+// which case the launcher neither installs nor starts Ollama itself.
+func (m Model) ollamaRemote() bool {
+	return m.remote("ollama")
+}
+
+// ollamaModels lists the names of the models the Ollama server has,
+// e.g. "nomic-embed-text:latest".
+func (m Model) ollamaModels(ctx context.Context) ([]string, error) {
+	body, ok := fetchHealth(ctx, m.ollamaURL("/api/tags"), "", m.probe.timeout)
+	if !ok {
+		return nil, fmt.Errorf("Ollama API at %s is not reachable", m.ollamaURL(""))
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("unexpected /api/tags response: %v", err)
+	}
+	names := make([]string, len(tags.Models))
+	for i, model := range tags.Models {
+		names[i] = model.Name
+	}
+	return names, nil
+}
+
+// hasModels reports whether every one of models is in names.
+func hasModels(names, models []string) bool {
+	for _, model := range models {
+		if !hasModel(names, model) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasModel reports whether model is in names. A model asked for without a
+// tag matches its :latest tag, as it does in ollama pull.
+func hasModel(names []string, model string) bool {
+	for _, name := range names {
+		if name == model || (!strings.Contains(model, ":") && name == model+":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+// pullProgress is one event of Ollama's streaming /api/pull response.
+type pullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// ollamaPull pulls model through the Ollama server's /api/pull endpoint,
+// calling onProgress with the bytes completed and total across all layers.
+// Layers that were partially downloaded before resume from where they
+// stopped, and a model that is already present finishes straight away.
+func (m Model) ollamaPull(ctx context.Context, model string, onProgress func(completed, total int64)) error {
+	body, _ := json.Marshal(map[string]any{"model": model, "name": model, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.ollamaURL("/api/pull"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama pull returned %s", resp.Status)
+	}
+
+	layers := make(map[string]pullProgress)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event pullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Error != "" {
+			return errors.New(event.Error)
+		}
+		if event.Status == "success" {
+			return nil
+		}
+		if event.Digest == "" || event.Total == 0 {
+			continue
+		}
+
+		layers[event.Digest] = event
+		var completed, total int64
+		for _, layer := range layers {
+			completed += layer.Completed
+			total += layer.Total
+		}
+		onProgress(completed, total)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("ollama pull ended without reporting success")
+}
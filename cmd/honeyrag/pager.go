@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pagerClosedMsg is sent when the pager opened with 'o' for step index
+// exits, or couldn't be started.
+type pagerClosedMsg struct {
+	index int
+	err   error
+}
+
+// stepLogPath returns the log file for step index: its service's log, or
+// the launcher's own log for steps that don't start a service.
+func (m Model) stepLogPath(index int) string {
+	if file := m.steps[index].LogFile; file != "" {
+		return filepath.Join(m.logsDir, file)
+	}
+	return filepath.Join(m.logsDir, "honeyrag.log")
+}
+
+// pagerCommand builds the command that shows path: $PAGER if set, else less
+// (opened at the end of the file) or more.
+func pagerCommand(path string) (*exec.Cmd, error) {
+	if pager := os.Getenv("PAGER"); pager != "" {
+		args, err := splitArgs(pager)
+		if err != nil {
+			return nil, fmt.Errorf("PAGER: %v", err)
+		}
+		if len(args) > 0 {
+			return exec.Command(args[0], append(args[1:], path)...), nil
+		}
+	}
+	if less, err := exec.LookPath("less"); err == nil {
+		return exec.Command(less, "+G", path), nil
+	}
+	if more, err := exec.LookPath("more"); err == nil {
+		return exec.Command(more, path), nil
+	}
+	return nil, fmt.Errorf("no pager found: set PAGER or install less")
+}
+
+// openPager suspends the TUI and shows step index's log file in a pager.
+func (m Model) openPager(index int) tea.Cmd {
+	path := m.stepLogPath(index)
+	if _, err := os.Stat(path); err != nil {
+		return func() tea.Msg { return pagerClosedMsg{index: index, err: fmt.Errorf("no log yet at %s", path)} }
+	}
+	cmd, err := pagerCommand(path)
+	if err != nil {
+		return func() tea.Msg { return pagerClosedMsg{index: index, err: err} }
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg { return pagerClosedMsg{index: index, err: err} })
+}
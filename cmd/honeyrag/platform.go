@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// checkPlatform reports, before anything starts, a pipeline that can't run
+// on this OS, with what to do instead. Process handling differs per OS
+// behind build tags (proc_unix.go, proc_windows.go); this covers what no
+// amount of that can paper over.
+func (m Model) checkPlatform() error {
+	if runtime.GOOS == "windows" && m.hasStep("vllm") && !m.remote("vllm") {
+		return errors.New("vLLM does not run on Windows. Set HONEYRAG_LLM_BACKEND=ollama to serve the LLM with Ollama, " +
+			"point LLM_BINDING_HOST or VLLM_HOST at a server elsewhere, or run honeyrag inside WSL 2")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// validatePort checks that port is a TCP port number a service can listen on.
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q is not a valid port (1-65535)", port)
+	}
+	return nil
+}
+
+// checkPortAvailable returns an error naming the owning process if port
+// can't be bound on host, the address the service will listen on. It is
+// called after the health check, so anything listening here is not a
+// healthy instance of the service we are about to start.
+func checkPortAvailable(host, port string) error {
+	if canListen(host, port) {
+		return nil
+	}
+	return fmt.Errorf("port %s already in use by %s", port, describePortOwner(port))
+}
+
+// autoPortRange is how far above a taken port HONEYRAG_AUTO_PORT looks for a
+// free one.
+const autoPortRange = 100
+
+// port returns the port of service key: the configured one, unless the Port
+// Check step moved it.
+func (m Model) port(key string) string {
+	m.portsMu.RLock()
+	defer m.portsMu.RUnlock()
+	return m.ports[key]
+}
+
+func (m Model) setPort(key, port string) {
+	m.portsMu.Lock()
+	defer m.portsMu.Unlock()
+	m.ports[key] = port
+}
+
+// portSnapshot is a copy of the ports in use.
+func (m Model) portSnapshot() map[string]string {
+	m.portsMu.RLock()
+	defer m.portsMu.RUnlock()
+	return maps.Clone(m.ports)
+}
+
+// configuredPorts is portSnapshot with the ports HONEYRAG_AUTO_PORT moved
+// put back to what the configuration says.
+func (m Model) configuredPorts() map[string]string {
+	ports := m.portSnapshot()
+	m.portsMu.RLock()
+	defer m.portsMu.RUnlock()
+	maps.Copy(ports, m.movedPorts)
+	return ports
+}
+
+// portMoves describes each port HONEYRAG_AUTO_PORT moved, e.g. "agent: 8081
+// in use → using 8082".
+func (m Model) portMoves() []string {
+	m.portsMu.RLock()
+	defer m.portsMu.RUnlock()
+	var moves []string
+	for _, svc := range services {
+		if from, ok := m.movedPorts[svc.portKey]; ok {
+			moves = append(moves, fmt.Sprintf("%s: %s in use %s using %s", svc.name, from, glyphs.arrow, m.ports[svc.portKey]))
+		}
+	}
+	return moves
+}
+
+// movePort moves service key off its taken port to the first one above it
+// that can be bound and that no other local service is set to use.
+func (m Model) movePort(key string) (string, error) {
+	taken := make(map[string]bool)
+	for _, svc := range m.activeServices() {
+		if svc.portKey != key && !m.remote(svc.portKey) {
+			taken[m.port(svc.portKey)] = true
+		}
+	}
+	from := m.port(key)
+	start, _ := strconv.Atoi(from)
+	for n := start + 1; n <= min(start+autoPortRange, 65535); n++ {
+		port := strconv.Itoa(n)
+		if taken[port] || !canListen(m.bindHost(key), port) {
+			continue
+		}
+		m.portsMu.Lock()
+		m.ports[key] = port
+		m.movedPorts[key] = from
+		m.portsMu.Unlock()
+		return port, nil
+	}
+	return "", fmt.Errorf("no free port from %d to %d", start+1, min(start+autoPortRange, 65535))
+}
+
+// checkPorts is the preflight step: it makes sure every configured port can
+// be bound, or is already held by an instance of its own service (healthy,
+// or one with another config that the service's step will deal with), so a
+// squatting process is reported up front instead of after a long timeout.
+// With HONEYRAG_AUTO_PORT, a service whose port is held by anything else is
+// moved to a free one instead; services started later, and those pointed at
+// them, get the new port.
+func (m Model) checkPorts(ctx context.Context, index int) error {
+	var conflicts []string
+	for _, svc := range m.activeServices() {
+		if m.remote(svc.portKey) {
+			continue
+		}
+		port := m.port(svc.portKey)
+		if canListen(m.bindHost(svc.portKey), port) || m.verifyService(ctx, svc.portKey) || m.staleService(ctx, svc.portKey) != "" {
+			continue
+		}
+		conflict := fmt.Sprintf("port %s (%s) is in use by %s", port, svc.label, describePortOwner(port))
+		if auto, _ := strconv.ParseBool(m.config["autoPort"]); auto {
+			moved, err := m.movePort(svc.portKey)
+			if err == nil {
+				m.logStep(index, "%s, using %s instead", conflict, moved)
+				continue
+			}
+			conflict += ": " + err.Error()
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(conflicts) > 0 {
+		return errors.New(strings.Join(conflicts, "\n"))
+	}
+	if moves := m.portMoves(); len(moves) > 0 {
+		moved := strings.Join(moves, glyphs.sep)
+		m.notifier.notify(logUpdateMsg{index: index, line: moved, info: moved})
+	}
+	return nil
+}
+
+// canListen reports whether port can be bound on host right now. host is
+// the service's bindHost, so the check sees the same conflicts the service
+// will.
+func canListen(host, port string) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// describePortOwner names the process listening on port as well as it can.
+func describePortOwner(port string) string {
+	pid := portOwner(port)
+	if pid == 0 {
+		return "another process"
+	}
+	if cmdline, err := processCommandLine(pid); err == nil && cmdline != "" {
+		return fmt.Sprintf("PID %d (%s)", pid, filepath.Base(strings.Fields(cmdline)[0]))
+	}
+	return fmt.Sprintf("PID %d", pid)
+}
+
+// portOwner returns the PID listening on TCP port, or 0 if it can't be
+// determined. It reads /proc on Linux and falls back to lsof elsewhere.
+func portOwner(port string) int {
+	if pid := procPortOwner(port); pid != 0 {
+		return pid
+	}
+
+	out, err := exec.Command("lsof", "-nP", "-t", "-iTCP:"+port, "-sTCP:LISTEN").Output()
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0
+	}
+	pid, _ := strconv.Atoi(fields[0])
+	return pid
+}
+
+func procPortOwner(port string) int {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+	want := fmt.Sprintf(":%04X", p)
+
+	inodes := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != "0A" {
+				continue
+			}
+			if strings.HasSuffix(fields[1], want) {
+				inodes["socket:["+fields[9]+"]"] = true
+			}
+		}
+		f.Close()
+	}
+	if len(inodes) == 0 {
+		return 0
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !inodes[link] {
+			continue
+		}
+		pid, _ := strconv.Atoi(strings.Split(fd, "/")[2])
+		return pid
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcAttr puts the child in its own process group so signals reach the
+// whole tree (uv run spawns the actual server as a grandchild).
+func setProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminateProcess(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGTERM)
+}
+
+func killProcess(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
+
+// killedOutright reports whether a child ended on SIGKILL, which is what the
+// kernel's out-of-memory killer sends. uv run passes its child's fate on as
+// exit status 137.
+func killedOutright(state *os.ProcessState) bool {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal() == syscall.SIGKILL
+	}
+	return state.ExitCode() == 128+int(syscall.SIGKILL)
+}
+
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+// processCommandLine returns the full command line of pid, from /proc where
+// available and ps(1) elsewhere.
+func processCommandLine(pid int) (string, error) {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		return strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " ")), nil
+	}
+	out, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
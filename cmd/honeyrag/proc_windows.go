@@ -0,0 +1,66 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var generateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+// setProcAttr starts the child in a new process group, so a Ctrl+Break can
+// be sent to it and whatever it runs (uv run spawns the actual server as a
+// grandchild) without reaching honeyrag itself.
+func setProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateProcess sends Ctrl+Break to the process group, Windows' nearest
+// thing to SIGTERM. That only works on a group sharing honeyrag's console;
+// for anything else, taskkill asks the whole tree to close.
+func terminateProcess(p *os.Process) error {
+	if r, _, _ := generateConsoleCtrlEvent.Call(syscall.CTRL_BREAK_EVENT, uintptr(p.Pid)); r != 0 {
+		return nil
+	}
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(p.Pid)).Run()
+}
+
+// killedOutright reports whether a child was killed by the kernel's
+// out-of-memory killer, which Windows doesn't have.
+func killedOutright(state *os.ProcessState) bool {
+	return false
+}
+
+// killProcess forcibly ends p and every process it started.
+func killProcess(p *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
+func processAlive(pid int) bool {
+	out, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/NH").Output()
+	return err == nil && strings.Contains(string(out), fmt.Sprint(pid))
+}
+
+// processCommandLine returns the full command line of pid, as recorded by
+// WMI, with .exe dropped from program names (and the quote after one) so
+// that it reads like a Unix one, e.g. ...\ollama serve.
+func processCommandLine(pid int) (string, error) {
+	query := fmt.Sprintf("(Get-CimInstance Win32_Process -Filter 'ProcessId=%d').CommandLine", pid)
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", query).Output()
+	if err != nil {
+		return "", err
+	}
+	cmdline := strings.TrimSpace(string(out))
+	if cmdline == "" {
+		return "", fmt.Errorf("no command line for process %d", pid)
+	}
+	return strings.NewReplacer(`.exe"`, "", ".exe", "").Replace(cmdline), nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// managedProcess is a child process started by the launcher. done is closed
+// once the process has exited and been reaped.
+type managedProcess struct {
+	name string
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// processGroup tracks every service honeyrag starts so they can be torn down
+// when the launcher exits. It is shared by pointer between Model copies.
+//
+// Each running service also gets a <name>.pid file in pidDir so that
+// `honeyrag stop` can find it after the launcher itself is gone.
+type processGroup struct {
+	pidDir string
+	events *runLog
+	mu     sync.Mutex
+	procs  []*managedProcess
+}
+
+// start launches cmd as service name and registers it with the group.
+func (g *processGroup) start(name string, cmd *exec.Cmd) (*managedProcess, error) {
+	setProcAttr(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	pidPath := g.pidPath(name)
+	os.WriteFile(pidPath, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0644)
+	g.events.event(name, "started pid=%d", cmd.Process.Pid)
+
+	proc := &managedProcess{name: name, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		os.Remove(pidPath)
+		g.events.event(name, "exited pid=%d (%v)", cmd.Process.Pid, cmd.ProcessState)
+		close(proc.done)
+	}()
+
+	g.mu.Lock()
+	g.procs = append(g.procs, proc)
+	g.mu.Unlock()
+	return proc, nil
+}
+
+// adopt takes over service name running as pid, started by an earlier
+// honeyrag whose pid file is still in pidDir, so that quitting or restarting
+// stops it like a process this run started. The process isn't our child and
+// can't be waited for, so it is polled until it goes away.
+func (g *processGroup) adopt(name string, pid int) (*managedProcess, error) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	g.events.event(name, "adopted pid=%d", pid)
+
+	proc := &managedProcess{name: name, cmd: &exec.Cmd{Process: p}, done: make(chan struct{})}
+	go func() {
+		for processAlive(pid) {
+			time.Sleep(time.Second)
+		}
+		// A replacement may have written its own pid file by now.
+		if data, err := os.ReadFile(g.pidPath(name)); err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(pid) {
+			os.Remove(g.pidPath(name))
+		}
+		g.events.event(name, "exited pid=%d", pid)
+		close(proc.done)
+	}()
+
+	g.mu.Lock()
+	g.procs = append(g.procs, proc)
+	g.mu.Unlock()
+	return proc, nil
+}
+
+// stopAll sends SIGTERM to every tracked process in reverse start order,
+// waits up to grace for them to exit and SIGKILLs whatever is left.
+func (g *processGroup) stopAll(grace time.Duration) {
+	g.mu.Lock()
+	procs := g.procs
+	g.procs = nil
+	g.mu.Unlock()
+
+	for i, j := 0, len(procs)-1; i < j; i, j = i+1, j-1 {
+		procs[i], procs[j] = procs[j], procs[i]
+	}
+	stopProcesses(procs, grace)
+}
+
+// stop stops the processes started for service name and forgets them. It
+// reports whether any were running.
+func (g *processGroup) stop(name string, grace time.Duration) bool {
+	g.mu.Lock()
+	var matched, kept []*managedProcess
+	for _, proc := range g.procs {
+		if proc.name == name {
+			matched = append(matched, proc)
+		} else {
+			kept = append(kept, proc)
+		}
+	}
+	g.procs = kept
+	g.mu.Unlock()
+
+	running := false
+	for _, proc := range matched {
+		running = running || !proc.exited()
+	}
+	stopProcesses(matched, grace)
+	return running
+}
+
+func stopProcesses(procs []*managedProcess, grace time.Duration) {
+	for _, proc := range procs {
+		if !proc.exited() {
+			terminateProcess(proc.cmd.Process)
+		}
+	}
+
+	deadline := time.After(grace)
+	for _, proc := range procs {
+		select {
+		case <-proc.done:
+		case <-deadline:
+			for _, proc := range procs {
+				if !proc.exited() {
+					killProcess(proc.cmd.Process)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (g *processGroup) pidPath(name string) string {
+	return filepath.Join(g.pidDir, name+".pid")
+}
+
+func (p *managedProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// pid returns the pid of the live process started or adopted for service
+// name, or 0 if there is none.
+func (g *processGroup) pid(name string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := len(g.procs) - 1; i >= 0; i-- {
+		proc := g.procs[i]
+		if proc.name == name && !proc.exited() && proc.cmd.Process != nil {
+			return proc.cmd.Process.Pid
+		}
+	}
+	return 0
+}
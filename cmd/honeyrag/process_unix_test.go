@@ -0,0 +1,141 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGroup(t *testing.T) *processGroup {
+	t.Helper()
+	dir := t.TempDir()
+	g := &processGroup{pidDir: dir, events: &runLog{path: filepath.Join(dir, "honeyrag.log")}}
+	t.Cleanup(func() { g.stopAll(time.Second) })
+	return g
+}
+
+// waitExited fails the test unless proc exits within d.
+func waitExited(t *testing.T, proc *managedProcess, d time.Duration) {
+	t.Helper()
+	select {
+	case <-proc.done:
+	case <-time.After(d):
+		t.Fatalf("%s (pid %d) still running after %s", proc.name, proc.cmd.Process.Pid, d)
+	}
+}
+
+func TestProcessGroupStartAndStopAll(t *testing.T) {
+	g := newTestGroup(t)
+	proc, err := g.start("vllm", exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := proc.cmd.Process.Pid
+
+	if len(g.procs) != 1 || g.procs[0] != proc {
+		t.Fatalf("procs = %v, want just the started process", g.procs)
+	}
+	if got := g.pid("vllm"); got != pid {
+		t.Errorf("pid(vllm) = %d, want %d", got, pid)
+	}
+	data, err := os.ReadFile(filepath.Join(g.pidDir, "vllm.pid"))
+	if err != nil {
+		t.Fatalf("pid file: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != strconv.Itoa(pid) {
+		t.Errorf("pid file holds %q, want %d", got, pid)
+	}
+
+	g.stopAll(5 * time.Second)
+	waitExited(t, proc, time.Second)
+	if g.procs != nil {
+		t.Errorf("procs = %v after stopAll, want none", g.procs)
+	}
+	if g.pid("vllm") != 0 {
+		t.Errorf("pid(vllm) = %d after stopAll, want 0", g.pid("vllm"))
+	}
+	if _, err := os.Stat(filepath.Join(g.pidDir, "vllm.pid")); !os.IsNotExist(err) {
+		t.Errorf("pid file still there after stopAll (%v)", err)
+	}
+	if processAlive(pid) {
+		t.Errorf("pid %d still alive after stopAll", pid)
+	}
+
+	events, _ := os.ReadFile(g.events.path)
+	for _, want := range []string{"[vllm] started pid=" + strconv.Itoa(pid), "[vllm] exited pid=" + strconv.Itoa(pid)} {
+		if !strings.Contains(string(events), want) {
+			t.Errorf("run log lacks %q:\n%s", want, events)
+		}
+	}
+}
+
+func TestProcessGroupStopOne(t *testing.T) {
+	g := newTestGroup(t)
+	lightrag, err := g.start("lightrag", exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := g.start("agent", exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !g.stop("lightrag", 5*time.Second) {
+		t.Error("stop(lightrag) = false, want true for a running process")
+	}
+	waitExited(t, lightrag, time.Second)
+	if agent.exited() {
+		t.Error("stop(lightrag) also stopped the agent")
+	}
+	if len(g.procs) != 1 || g.procs[0] != agent {
+		t.Errorf("procs = %v, want just the agent", g.procs)
+	}
+	if g.stop("lightrag", time.Second) {
+		t.Error("stop(lightrag) = true a second time, want false")
+	}
+}
+
+func TestProcessGroupExitedProcess(t *testing.T) {
+	g := newTestGroup(t)
+	proc, err := g.start("ollama", exec.Command("true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitExited(t, proc, 5*time.Second)
+
+	if _, err := os.Stat(filepath.Join(g.pidDir, "ollama.pid")); !os.IsNotExist(err) {
+		t.Errorf("pid file still there after the process exited (%v)", err)
+	}
+	if g.pid("ollama") != 0 {
+		t.Errorf("pid(ollama) = %d for an exited process, want 0", g.pid("ollama"))
+	}
+	if g.stop("ollama", time.Second) {
+		t.Error("stop(ollama) = true for an exited process, want false")
+	}
+}
+
+func TestProcessGroupStopAllKillsAfterGrace(t *testing.T) {
+	g := newTestGroup(t)
+	// The ignored SIGTERM is inherited by sleep, so only SIGKILL ends them.
+	proc, err := g.start("vllm", exec.Command("sh", "-c", `trap "" TERM; sleep 30`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	g.stopAll(200 * time.Millisecond)
+	waitExited(t, proc, 5*time.Second)
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("process ignoring SIGTERM exited after %s, before the grace period", elapsed)
+	}
+	if !killedOutright(proc.cmd.ProcessState) {
+		t.Errorf("process ended with %v, want SIGKILL", proc.cmd.ProcessState)
+	}
+}
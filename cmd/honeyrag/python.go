@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// preferredPython is installed with `uv python install` when no installed
+// interpreter suits the project, provided requires-python allows it.
+const preferredPython = "3.12"
+
+var requiresPythonPattern = regexp.MustCompile(`(?m)^\s*requires-python\s*=\s*["']([^"']*)["']`)
+
+// requiresPython returns the requires-python specifier from pyproject.toml in
+// baseDir, e.g. ">=3.11,<3.14", or "" if it sets none.
+func requiresPython(baseDir string) string {
+	data, err := os.ReadFile(filepath.Join(baseDir, "pyproject.toml"))
+	if err != nil {
+		return ""
+	}
+	if match := requiresPythonPattern.FindSubmatch(data); match != nil {
+		return strings.TrimSpace(string(match[1]))
+	}
+	return ""
+}
+
+// pythonVersion is a dotted release number such as 3.12.4.
+type pythonVersion []int
+
+func parsePythonVersion(s string) (pythonVersion, bool) {
+	var v pythonVersion
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		v = append(v, n)
+	}
+	return v, len(v) > 0
+}
+
+func (v pythonVersion) String() string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// compare orders v and w, padding the shorter one with zeros.
+func (v pythonVersion) compare(w pythonVersion) int {
+	for i := range max(len(v), len(w)) {
+		a, b := 0, 0
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(w) {
+			b = w[i]
+		}
+		if a != b {
+			return a - b
+		}
+	}
+	return 0
+}
+
+// satisfies reports whether v meets a PEP 440 specifier set such as
+// ">=3.11,<3.14". It understands the operators pyproject files use for
+// Python versions: comparisons, ==/!= with an optional .* and ~=. An
+// empty specifier allows anything; one it can't parse allows nothing.
+func (v pythonVersion) satisfies(spec string) bool {
+	for _, clause := range strings.Split(spec, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		i := strings.IndexFunc(clause, func(r rune) bool { return r >= '0' && r <= '9' })
+		if i <= 0 {
+			return false
+		}
+		op, operand := strings.TrimSpace(clause[:i]), clause[i:]
+		wildcard := strings.HasSuffix(operand, ".*")
+		want, ok := parsePythonVersion(strings.TrimSuffix(operand, ".*"))
+		if !ok {
+			return false
+		}
+
+		var match bool
+		switch op {
+		case "==", "!=":
+			if wildcard {
+				match = len(v) >= len(want) && v[:len(want)].compare(want) == 0
+			} else {
+				match = v.compare(want) == 0
+			}
+			if op == "!=" {
+				match = !match
+			}
+		case ">=":
+			match = v.compare(want) >= 0
+		case ">":
+			match = v.compare(want) > 0
+		case "<=":
+			match = v.compare(want) <= 0
+		case "<":
+			match = v.compare(want) < 0
+		case "~=":
+			// ~=3.11 means >=3.11,==3; ~=3.11.2 means >=3.11.2,==3.11.*.
+			if len(want) < 2 {
+				return false
+			}
+			prefix := want[:len(want)-1]
+			match = v.compare(want) >= 0 && len(v) >= len(prefix) && v[:len(prefix)].compare(prefix) == 0
+		default:
+			return false
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// installedPythons lists the interpreters uv can find on this machine, its
+// own managed ones and those on PATH, newest first.
+func installedPythons(ctx context.Context) ([]pythonVersion, error) {
+	out, err := exec.CommandContext(ctx, uvCommand(), "python", "list", "--only-installed").Output()
+	if err != nil {
+		return nil, fmt.Errorf("uv python list: %v", err)
+	}
+	return parsePythonList(string(out)), nil
+}
+
+// pythonListPattern picks the version out of a `uv python list` key such as
+// cpython-3.12.4-linux-x86_64-gnu. Free-threaded builds (3.13.0+freethreaded)
+// are skipped: most wheels don't support them yet.
+var pythonListPattern = regexp.MustCompile(`^cpython-(\d+\.\d+\.\d+)-`)
+
+func parsePythonList(out string) []pythonVersion {
+	var versions []pythonVersion
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		match := pythonListPattern.FindStringSubmatch(fields[0])
+		if match == nil {
+			continue
+		}
+		if v, ok := parsePythonVersion(match[1]); ok && !slices.ContainsFunc(versions, func(w pythonVersion) bool { return w.compare(v) == 0 }) {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(a, b pythonVersion) int { return b.compare(a) })
+	return versions
+}
+
+// pickPython returns the newest of installed that satisfies spec.
+func pickPython(installed []pythonVersion, spec string) (pythonVersion, bool) {
+	for _, v := range installed {
+		if v.satisfies(spec) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// pythonToInstall is the version to ask uv for when nothing installed
+// suits spec: preferredPython if spec allows it, else the newest released
+// 3.x minor version it allows.
+func pythonToInstall(spec string) (string, bool) {
+	if v, _ := parsePythonVersion(preferredPython); v.satisfies(spec) {
+		return preferredPython, true
+	}
+	for minor := 14; minor >= 8; minor-- {
+		if v := (pythonVersion{3, minor}); v.satisfies(spec) {
+			return v.String(), true
+		}
+	}
+	return "", false
+}
+
+// neededPython returns the version the deps step would have to install
+// because nothing installed suits the project, or "" when something does or
+// it can't tell.
+func (m Model) neededPython(ctx context.Context) string {
+	if uvBinary() == "" {
+		return ""
+	}
+	spec := requiresPython(m.baseDir)
+	installed, err := installedPythons(ctx)
+	if err != nil {
+		return ""
+	}
+	if _, ok := pickPython(installed, spec); ok {
+		return ""
+	}
+	if version, ok := pythonToInstall(spec); ok {
+		return version
+	}
+	return ""
+}
+
+// confirmPythonInstall asks on the terminal, before the TUI takes it over,
+// whether uv may install a Python the project can use. It returns false
+// without asking when one is already installed.
+func (m Model) confirmPythonInstall() bool {
+	version := m.neededPython(context.Background())
+	if version == "" {
+		return false
+	}
+	return confirm(fmt.Sprintf("No installed Python matches requires-python %q. Install Python %s with uv?", requiresPython(m.baseDir), version))
+}
+
+// choosePython picks the interpreter for `uv sync`: the newest installed one
+// that satisfies requires-python, or one uv installs if the user agreed to
+// it. It returns "" to leave the choice to uv when the installed versions
+// can't be listed.
+func (m Model) choosePython(ctx context.Context, index int) (string, error) {
+	spec := requiresPython(m.baseDir)
+	installed, err := installedPythons(ctx)
+	if err != nil {
+		return "", nil
+	}
+
+	chosen, ok := pickPython(installed, spec)
+	version := chosen.String()
+	if !ok {
+		found := make([]string, len(installed))
+		for i, v := range installed {
+			found[i] = v.String()
+		}
+		if len(found) == 0 {
+			found = []string{"none"}
+		}
+		want, ok := pythonToInstall(spec)
+		if !ok {
+			return "", fmt.Errorf("no Python version satisfies requires-python %q in pyproject.toml", spec)
+		}
+		if !m.consent.pythonInstall && !m.dryRun {
+			return "", fmt.Errorf("no installed Python matches requires-python %q (found: %s). Install one with `uv python install %s`, or re-run with --yes",
+				spec, strings.Join(found, ", "), want)
+		}
+
+		cmd := exec.CommandContext(ctx, uvCommand(), "python", "install", want)
+		cmd.Dir = m.baseDir
+		if m.dryRun {
+			m.showCommand(cmd)
+			return want, nil
+		}
+		m.notifier.notify(logUpdateMsg{index: index, line: "installing Python " + want, info: "installing Python " + want})
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", fmt.Errorf("uv python install %s failed: %v\n%s", want, err, output)
+		}
+		version = want
+	}
+
+	m.notifier.notify(logUpdateMsg{index: index, line: "using Python " + version, info: "Python " + version})
+	m.logStep(index, "using Python %s", version)
+	return version, nil
+}
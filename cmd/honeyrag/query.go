@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// agentID is the id services/agno/app.py gives the agent.
+const agentID = "honeyrag-agent"
+
+// lightragModes are the retrieval modes LightRAG's /query accepts.
+var lightragModes = []string{"naive", "local", "global", "hybrid", "mix"}
+
+// runQuery implements `honeyrag query [--mode M] [--json] [--timeout D]
+// "question"`: it asks the running agent, or with --mode LightRAG directly,
+// and streams the answer to stdout as it arrives. --json prints the whole
+// response instead, sources included. It exits 1 if the service isn't up
+// or the query fails, and 2 on bad usage.
+func runQuery(m Model, args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	mode := fs.String("mode", "", "ask LightRAG directly with this retrieval mode: "+strings.Join(lightragModes, ", "))
+	asJSON := fs.Bool("json", false, "print the raw response, with the retrieved sources, instead of streaming the answer")
+	timeout := fs.Duration("timeout", 2*time.Minute, "give up after this long")
+
+	// Flags may come before or after the question.
+	var words []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		words = append(words, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	question := strings.TrimSpace(strings.Join(words, " "))
+	if question == "" {
+		fmt.Fprintln(os.Stderr, `Usage: honeyrag query [--mode naive|local|global|hybrid|mix] [--json] [--timeout 2m] "question"`)
+		return 2
+	}
+	if *mode != "" && !slices.Contains(lightragModes, *mode) {
+		fmt.Fprintf(os.Stderr, "Error: --mode must be one of %s\n", strings.Join(lightragModes, ", "))
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	service, label := "agno", "Agent"
+	if *mode != "" {
+		service, label = "lightrag", "LightRAG"
+	}
+	if !m.verifyService(ctx, service) {
+		fmt.Fprintf(os.Stderr, "Error: %s is not answering at %s. Start the stack with honeyrag first\n", label, m.serviceURL(service, ""))
+		return 1
+	}
+
+	var err error
+	if *mode != "" {
+		err = m.queryLightRAG(ctx, question, *mode, *asJSON)
+	} else {
+		err = m.queryAgent(ctx, question, *asJSON)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("no complete answer within %s (--timeout)", *timeout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// queryAgent runs question through the agent's AgentOS endpoint. Streamed,
+// the answer comes as server-sent events whose RunContent events carry the
+// next piece of text.
+func (m Model) queryAgent(ctx context.Context, question string, asJSON bool) error {
+	form := url.Values{"message": {question}, "stream": {fmt.Sprint(!asJSON)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.serviceURL("agno", "/agents/"+agentID+"/runs"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	if asJSON {
+		return copyJSON(resp.Body)
+	}
+
+	return streamLines(resp.Body, func(data []byte) error {
+		var event struct {
+			Event   string `json:"event"`
+			Content any    `json:"content"`
+		}
+		if json.Unmarshal(data, &event) != nil {
+			return nil
+		}
+		switch event.Event {
+		case "RunContent", "RunResponseContent":
+			if text, ok := event.Content.(string); ok {
+				fmt.Print(text)
+			}
+		case "RunError":
+			return fmt.Errorf("agent run failed: %v", event.Content)
+		}
+		return nil
+	})
+}
+
+// queryLightRAG asks LightRAG itself. Streamed, /query/stream sends one JSON
+// object per line, each with the next piece of the answer.
+func (m Model) queryLightRAG(ctx context.Context, question, mode string, asJSON bool) error {
+	path := "/query/stream"
+	if asJSON {
+		path = "/query"
+	}
+	body, _ := json.Marshal(map[string]any{"query": question, "mode": mode, "stream": !asJSON})
+	resp, err := m.lightragRequest(ctx, http.MethodPost, path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	if asJSON {
+		return copyJSON(resp.Body)
+	}
+
+	return streamLines(resp.Body, func(data []byte) error {
+		var chunk struct {
+			Response string `json:"response"`
+			Error    string `json:"error"`
+		}
+		if json.Unmarshal(data, &chunk) != nil {
+			return nil
+		}
+		if chunk.Error != "" {
+			return errors.New(chunk.Error)
+		}
+		fmt.Print(chunk.Response)
+		return nil
+	})
+}
+
+// streamLines calls onData with each line of body as it arrives, without an
+// SSE "data:" prefix, and ends the answer with a newline.
+func streamLines(body io.Reader, onData func([]byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(bytes.TrimPrefix(scanner.Bytes(), []byte("data:")))
+		if len(line) == 0 {
+			continue
+		}
+		if err := onData(line); err != nil {
+			fmt.Println()
+			return err
+		}
+	}
+	fmt.Println()
+	return scanner.Err()
+}
+
+// responseError describes a response that isn't a 200, with the start of
+// its body.
+func responseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s returned %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// copyJSON prints a JSON response indented, or as it is if it isn't JSON.
+func copyJSON(body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, data, "", "  ") != nil {
+		out.Reset()
+		out.Write(data)
+	}
+	out.WriteString("\n")
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
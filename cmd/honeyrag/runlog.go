@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runLog is the launcher's own log, logs/honeyrag.log: one chronological,
+// timestamped line per step transition or process event across every
+// service, e.g.
+//
+//	2024-01-02T10:00:01Z [vllm] started pid=4242
+//
+// The file is opened on the first event so commands that never log, like
+// `honeyrag status`, don't create it. Logging is best effort: if the file
+// can't be opened, events are dropped.
+type runLog struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	err  error
+}
+
+// event appends one line for scope, typically a service or step name.
+func (l *runLog) event(scope, format string, args ...any) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil && l.err == nil {
+		l.file, l.err = os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	if l.file == nil {
+		return
+	}
+	fmt.Fprintf(l.file, "%s [%s] %s\n", time.Now().UTC().Format(time.RFC3339), scope, fmt.Sprintf(format, args...))
+}
+
+// stepScope names step index in the run log: its service if it starts one,
+// otherwise its name, e.g. "python-deps".
+func (m Model) stepScope(index int) string {
+	if svc := m.steps[index].Service; svc != "" {
+		return svc
+	}
+	return strings.ToLower(strings.ReplaceAll(m.steps[index].Name, " ", "-"))
+}
+
+// logStep records a step transition in the run log.
+func (m Model) logStep(index int, format string, args ...any) {
+	m.events.event(m.stepScope(index), format, args...)
+}
+
+// firstLine returns the first line of err, leaving out the log tails that
+// service failures carry; those are already in the service's own log.
+func firstLine(err error) string {
+	line, _, _ := strings.Cut(err.Error(), "\n")
+	return line
+}
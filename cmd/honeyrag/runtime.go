@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+)
+
+// runtimeState is the mutable state shared by every copy of the Model.
+// Bubble Tea passes the Model by value and each step runs on its own
+// goroutine with the copy it was dispatched from, so anything a step changes
+// or that has to survive past a single Update lives behind this pointer.
+type runtimeState struct {
+	processes *processGroup
+	notifier  *notifier
+	events    *runLog
+
+	// portsMu guards Model.ports, which the Port Check step may change
+	// with HONEYRAG_AUTO_PORT; movedPorts holds the configured port of
+	// each service moved that way.
+	portsMu    sync.RWMutex
+	movedPorts map[string]string
+
+	// ctx is cancelled when the user quits or a step fails, aborting any
+	// in-flight health waits and setup commands. Steps started after a
+	// failure (a retry, a skip's dependents, a restart) get a fresh one
+	// from renewContext.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newRuntimeState(logsDir string) *runtimeState {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := &runLog{path: filepath.Join(logsDir, "honeyrag.log")}
+	return &runtimeState{
+		processes:  &processGroup{pidDir: logsDir, events: events},
+		notifier:   &notifier{},
+		events:     events,
+		movedPorts: make(map[string]string),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// renewContext replaces ctx once it has been cancelled by a failed step.
+func (s *runtimeState) renewContext() {
+	if s.ctx.Err() != nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Service modes for selectSteps.
+const (
+	serviceStart    = "start"
+	serviceExternal = "external"
+	serviceOmit     = "omit"
+)
+
+// selectSteps narrows steps to what --only, --without and --skip ask for,
+// each a comma-separated list of service names. Services named in only
+// (every service if it is empty) are started, along with the steps they
+// depend on; steps that aren't needed are dropped entirely. Services named
+// in skip, and those outside only that a started one needs, are taken to be
+// running already: their steps only check that they answer. It is an error
+// to leave out with without a service that another needs, or to name a
+// service in more than one flag.
+func selectSteps(steps []Step, only, without, skip string) ([]Step, error) {
+	if only == "" && without == "" && skip == "" {
+		return steps, nil
+	}
+	mode := make(map[string]string)
+	for _, step := range steps {
+		if step.Service != "" && only == "" {
+			mode[step.Service] = serviceStart
+		} else if step.Service != "" {
+			mode[step.Service] = ""
+		}
+	}
+	setBy := make(map[string]string)
+	for _, f := range []struct{ flagName, list, mode string }{
+		{"--only", only, serviceStart}, {"--without", without, serviceOmit}, {"--skip", skip, serviceExternal},
+	} {
+		for _, name := range strings.Split(f.list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, ok := lookupService(name); !ok {
+				return nil, fmt.Errorf("%s: unknown service %q (known: %s)", f.flagName, name, serviceNames())
+			}
+			if _, ok := mode[name]; !ok {
+				return nil, fmt.Errorf("%s: %s is not started with the configured HONEYRAG_LLM_BACKEND", f.flagName, name)
+			}
+			if other, ok := setBy[name]; ok && other != f.flagName {
+				return nil, fmt.Errorf("%s and %s both name %s", other, f.flagName, name)
+			}
+			setBy[name] = f.flagName
+			mode[name] = f.mode
+		}
+	}
+
+	// Walk the dependencies of the services to start. Those of a service
+	// that is already running don't matter.
+	byKey := make(map[string]Step)
+	for _, step := range steps {
+		byKey[step.Key] = step
+	}
+	needed := make(map[string]bool)
+	var queue []string
+	for _, step := range steps {
+		if step.Service != "" && (mode[step.Service] == serviceStart || mode[step.Service] == serviceExternal) {
+			queue = append(queue, step.Key)
+		}
+	}
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("--only/--without leave no service to start")
+	}
+	// A step that works on running services, such as ingesting documents,
+	// comes along when all of them do.
+	for _, step := range steps {
+		if step.Service == "" && len(step.DependsOn) > 0 && !dependedOn(steps, step.Key) &&
+			!slices.ContainsFunc(step.DependsOn, func(key string) bool {
+				service := byKey[key].Service
+				return service == "" || (mode[service] != serviceStart && mode[service] != serviceExternal)
+			}) {
+			queue = append(queue, step.Key)
+		}
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		if needed[key] {
+			continue
+		}
+		needed[key] = true
+		if service := byKey[key].Service; service == "" || mode[service] == serviceStart {
+			queue = append(queue, byKey[key].DependsOn...)
+		}
+	}
+
+	var kept []Step
+	var missing, dependents []string
+	for _, step := range steps {
+		if !needed[step.Key] {
+			continue
+		}
+		if step.Service != "" && mode[step.Service] == serviceOmit {
+			missing = append(missing, step.Service)
+			for _, other := range steps {
+				if other.Service != "" && mode[other.Service] == serviceStart && dependsOn(byKey, other.Key, step.Key) &&
+					!slices.Contains(dependents, other.Service) {
+					dependents = append(dependents, other.Service)
+				}
+			}
+		}
+		if step.Service != "" && mode[step.Service] != serviceStart {
+			step = externalStep(step)
+		}
+		kept = append(kept, step)
+	}
+	if len(missing) > 0 {
+		verb := "needs"
+		if len(dependents) > 1 {
+			verb = "need"
+		}
+		return nil, fmt.Errorf("%s %s %s, which --without leaves out. Use --skip=%s instead if it is already running",
+			joinAnd(dependents), verb, joinAnd(missing), strings.Join(missing, ","))
+	}
+	return kept, nil
+}
+
+// externalStep turns the step that starts a service into one that only
+// checks that it is already running.
+func externalStep(step Step) Step {
+	step.External = true
+	step.DependsOn = nil
+	step.LogFile = ""
+	step.Extra = nil
+	step.Run = Model.checkExternal
+	step.Hint = "checking it answers..."
+	return step
+}
+
+// checkExternal is the step for a service honeyrag was told not to start: it
+// fails unless the service answers on its configured port (or host) with the
+// right model.
+func (m Model) checkExternal(ctx context.Context, index int) error {
+	svc, _ := lookupService(m.steps[index].Service)
+	url := m.serviceURL(svc.portKey, "")
+	if m.dryRun {
+		m.showAction("check that %s answers at %s", svc.label, url)
+		return nil
+	}
+	if stale := m.staleService(ctx, svc.portKey); stale != "" {
+		return fmt.Errorf("%s. A skipped service is used as it is; start the right one or let honeyrag start it", stale)
+	}
+	if !m.verifyService(ctx, svc.portKey) {
+		return fmt.Errorf("%s is skipped, but nothing answers at %s. Start it there, or let honeyrag start it", svc.label, url)
+	}
+	line := "using " + url
+	m.notifier.notify(logUpdateMsg{index: index, line: line, info: line})
+	m.logStep(index, "%s", line)
+	return nil
+}
+
+// joinAnd lists names as "a, b and c".
+func joinAnd(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// dependsOn reports whether the step with key from needs the one with key
+// target, directly or through other steps.
+func dependsOn(byKey map[string]Step, from, target string) bool {
+	for _, dep := range byKey[from].DependsOn {
+		if dep == target || dependsOn(byKey, dep, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependedOn reports whether any of steps waits for the step with key.
+func dependedOn(steps []Step, key string) bool {
+	return slices.ContainsFunc(steps, func(step Step) bool { return slices.Contains(step.DependsOn, key) })
+}
+
+// hasStep reports whether the pipeline runs the step with key itself: it is
+// there, not skipped and not a check on a service started elsewhere.
+func (m Model) hasStep(key string) bool {
+	for _, step := range m.steps {
+		if step.Key == key {
+			return step.Status != "skipped" && !step.External
+		}
+	}
+	return false
+}
+
+// usesStep reports whether the step with key is part of this run, whether it
+// starts its service or only checks it.
+func (m Model) usesStep(key string) bool {
+	return slices.ContainsFunc(m.steps, func(step Step) bool { return step.Key == key && step.Status != "skipped" })
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// service describes one of the long-running servers honeyrag manages.
+type service struct {
+	// name is used for pid and log files and on the command line.
+	name  string
+	label string
+	// portKey is the service's entry in Model.ports.
+	portKey string
+	// portEnv and defaultPort give the port when no --<name>-port flag is set.
+	portEnv     string
+	defaultPort string
+	// hostEnv names the variable that points honeyrag at an instance on
+	// another machine, read into Model.config[hostKey]; the agent has none.
+	hostEnv string
+	hostKey string
+	// command is a fragment of the command line the service is started
+	// with, used to make sure a PID from a pid file still belongs to it.
+	command string
+}
+
+// services lists the managed services in start order.
+var services = []service{
+	{name: "ollama", label: "Ollama", portKey: "ollama", portEnv: "OLLAMA_PORT", defaultPort: "11434", hostEnv: "OLLAMA_HOST", hostKey: "ollamaHost", command: "ollama serve"},
+	{name: "vllm", label: "vLLM", portKey: "vllm", portEnv: "VLLM_PORT", defaultPort: "8000", hostEnv: "VLLM_HOST", hostKey: "vllmHost", command: "vllm serve"},
+	{name: "lightrag", label: "LightRAG", portKey: "lightrag", portEnv: "LIGHTRAG_PORT", defaultPort: "9621", hostEnv: "LIGHTRAG_HOST", hostKey: "lightragHost", command: "lightrag-server"},
+	{name: "agent", label: "Agent", portKey: "agno", portEnv: "AGNO_PORT", defaultPort: "8081", command: "uvicorn app:app"},
+}
+
+func lookupService(name string) (service, bool) {
+	for _, svc := range services {
+		if svc.name == strings.ToLower(name) {
+			return svc, true
+		}
+	}
+	return service{}, false
+}
+
+// serviceByPortKey looks a service up by its Model.ports key.
+func serviceByPortKey(key string) (service, bool) {
+	for _, svc := range services {
+		if svc.portKey == key {
+			return svc, true
+		}
+	}
+	return service{}, false
+}
+
+func serviceNames() string {
+	names := make([]string, len(services))
+	for i, svc := range services {
+		names[i] = svc.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// runningPID returns the PID recorded for svc under logsDir and when it was
+// started, provided that process is still alive and still runs svc.
+func runningPID(logsDir string, svc service) (int, time.Time, bool) {
+	pidPath := filepath.Join(logsDir, svc.name+".pid")
+	data, err := os.ReadFile(pidPath)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !processAlive(pid) {
+		return 0, time.Time{}, false
+	}
+	if cmdline, err := processCommandLine(pid); err == nil && !strings.Contains(cmdline, svc.command) {
+		return 0, time.Time{}, false
+	}
+
+	// The pid file is written right after the process starts.
+	info, err := os.Stat(pidPath)
+	if err != nil {
+		return pid, time.Time{}, true
+	}
+	return pid, info.ModTime(), true
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/joho/godotenv"
+)
+
+// errSetupCancelled is returned when the user leaves the setup form with esc.
+var errSetupCancelled = errors.New("setup cancelled, configs/.env not written")
+
+// setupField is one setting the setup form asks for.
+type setupField struct {
+	env   string
+	label string
+	// check returns what is wrong with a value, or "" if it is fine.
+	check func(string) string
+}
+
+// setupForm is the guided setup shown before the first launch, or with
+// --configure: a few settings that every stack needs, written to
+// configs/.env when submitted.
+type setupForm struct {
+	fields    []setupField
+	inputs    []textinput.Model
+	problems  []string
+	focus     int
+	submitted bool
+	cancelled bool
+}
+
+// needsSetup reports whether the setup form should run: with --configure, or
+// when there is no configs/.env yet and someone is at the terminal to fill it
+// in. Without a terminal, --configure is an error.
+func needsSetup(baseDir string, configure bool) (bool, error) {
+	if configure {
+		if !isTerminal(os.Stdin) {
+			return false, errors.New("--configure needs a terminal; edit configs/.env instead")
+		}
+		return true, nil
+	}
+	_, err := os.Stat(filepath.Join(baseDir, "configs", ".env"))
+	return isTerminal(os.Stdin) && errors.Is(err, os.ErrNotExist), nil
+}
+
+// setupFields lists what the form asks for: the chat model and, when vLLM
+// serves it, its share of GPU memory, then the ports.
+func setupFields(backend string) []setupField {
+	var fields []setupField
+	nonEmpty := func(v string) string {
+		if v == "" {
+			return "enter a model name"
+		}
+		return ""
+	}
+	switch backend {
+	case llmBackendVLLM:
+		fields = append(fields,
+			setupField{env: "VLLM_MODEL", label: "vLLM model", check: nonEmpty},
+			setupField{env: "VLLM_GPU_MEMORY_UTILIZATION", label: "GPU memory share", check: func(v string) string {
+				if util, err := strconv.ParseFloat(v, 64); err != nil || !(util > 0 && util <= 1) {
+					return "a fraction greater than 0 and at most 1, e.g. 0.8"
+				}
+				return ""
+			}})
+	case llmBackendOllama:
+		fields = append(fields, setupField{env: "OLLAMA_LLM_MODEL", label: "Ollama model", check: nonEmpty})
+	}
+	for _, svc := range services {
+		fields = append(fields, setupField{env: svc.portEnv, label: svc.label + " port", check: func(v string) string {
+			if validatePort(v) != nil {
+				return "a port number from 1 to 65535"
+			}
+			return ""
+		}})
+	}
+	return fields
+}
+
+// setupDefaults returns the values the form starts with: those in
+// configs/.env if there is one, else in configs/.env.example, else the
+// built-in defaults.
+func setupDefaults(baseDir string) map[string]string {
+	values := map[string]string{
+		"VLLM_MODEL":                  "Qwen/Qwen2.5-1.5B-Instruct",
+		"VLLM_GPU_MEMORY_UTILIZATION": "0.8",
+		"OLLAMA_LLM_MODEL":            defaultOllamaLLM(),
+	}
+	for _, svc := range services {
+		values[svc.portEnv] = svc.defaultPort
+	}
+	for _, name := range []string{".env.example", ".env"} {
+		file, err := godotenv.Read(filepath.Join(baseDir, "configs", name))
+		if err != nil {
+			continue
+		}
+		for key, value := range file {
+			if value != "" {
+				values[key] = value
+			}
+		}
+	}
+	return values
+}
+
+// setupBackend is the LLM backend the stack will use, as far as can be told
+// before configs/.env exists.
+func setupBackend(values map[string]string) string {
+	if backend := os.Getenv("HONEYRAG_LLM_BACKEND"); backend != "" {
+		return backend
+	}
+	if backend := values["HONEYRAG_LLM_BACKEND"]; backend != "" {
+		return backend
+	}
+	return defaultLLMBackend(map[string]string{"llmHost": values["LLM_BINDING_HOST"], "vllmHost": values["VLLM_HOST"]})
+}
+
+func newSetupForm(baseDir string) setupForm {
+	values := setupDefaults(baseDir)
+	f := setupForm{fields: setupFields(setupBackend(values))}
+	for i, field := range f.fields {
+		input := textinput.New()
+		input.SetValue(values[field.env])
+		input.Prompt = ""
+		input.CharLimit = 200
+		if i == 0 {
+			input.Focus()
+		}
+		f.inputs = append(f.inputs, input)
+	}
+	f.problems = make([]string, len(f.fields))
+	return f
+}
+
+func (f setupForm) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (f setupForm) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "ctrl+c", "esc":
+			f.cancelled = true
+			return f, tea.Quit
+		case "tab", "down":
+			return f.move(1), nil
+		case "shift+tab", "up":
+			return f.move(-1), nil
+		case "enter":
+			if f.focus < len(f.inputs)-1 {
+				return f.move(1), nil
+			}
+			if f.check() {
+				f.submitted = true
+				return f, tea.Quit
+			}
+			return f, nil
+		}
+	}
+	if _, ok := msg.(tea.KeyMsg); ok {
+		f.problems[f.focus] = ""
+	}
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return f, cmd
+}
+
+// move focuses the field delta places away, wrapping around.
+func (f setupForm) move(delta int) setupForm {
+	f.inputs[f.focus].Blur()
+	f.focus = (f.focus + delta + len(f.inputs)) % len(f.inputs)
+	f.inputs[f.focus].Focus()
+	return f
+}
+
+// check validates every field, focusing the first one at fault. It reports
+// whether all of them are fine.
+func (f *setupForm) check() bool {
+	first := -1
+	for i, field := range f.fields {
+		f.problems[i] = field.check(strings.TrimSpace(f.inputs[i].Value()))
+		if f.problems[i] != "" && first < 0 {
+			first = i
+		}
+	}
+	if first >= 0 {
+		*f = f.move(first - f.focus)
+	}
+	return first < 0
+}
+
+func (f setupForm) View() string {
+	if f.submitted || f.cancelled {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(styles.title.Render(fmt.Sprintf("\n%s HoneyRAG setup", styles.honey.Render(glyphs.honey))))
+	b.WriteString("\n")
+	b.WriteString(styles.dim.Render("  These go in configs/.env; everything else keeps its default (see configs/.env.example)."))
+	b.WriteString("\n\n")
+	for i, field := range f.fields {
+		cursor := "  "
+		if i == f.focus {
+			cursor = styles.honey.Render(glyphs.cursor)
+		}
+		b.WriteString(fmt.Sprintf("%s%-18s %s\n", cursor, field.label, f.inputView(i)))
+		if f.problems[i] != "" {
+			b.WriteString(styles.error.Render(fmt.Sprintf("    %s: %s", field.env, f.problems[i])))
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(styles.dim.Render("  " + glyphs.updown + " or tab to move" + glyphs.sep + "enter on the last field to save" + glyphs.sep + "esc to cancel"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// inputView renders field i's input. bubbles draws the text cursor in
+// reverse video, so plain styles draw it as glyphs.caret instead.
+func (f setupForm) inputView(i int) string {
+	input := f.inputs[i]
+	if !styles.plain {
+		return input.View()
+	}
+	if i != f.focus {
+		return input.Value()
+	}
+	value := []rune(input.Value())
+	pos := min(input.Position(), len(value))
+	return string(value[:pos]) + glyphs.caret + string(value[pos:])
+}
+
+// runSetup shows the setup form and writes what was entered to
+// configs/.env, keeping everything else the file, or the example it is
+// started from, says.
+func runSetup(baseDir string) error {
+	final, err := tea.NewProgram(newSetupForm(baseDir)).Run()
+	if err != nil {
+		return err
+	}
+	f := final.(setupForm)
+	if !f.submitted {
+		return errSetupCancelled
+	}
+
+	values := make(map[string]string)
+	for i, field := range f.fields {
+		values[field.env] = strings.TrimSpace(f.inputs[i].Value())
+	}
+	path := filepath.Join(baseDir, "configs", ".env")
+	template, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		template, _ = os.ReadFile(filepath.Join(baseDir, "configs", ".env.example"))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, setEnvLines(template, values), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Saved configs/.env")
+	return nil
+}
+
+// setEnvLines sets each of values in the .env file content: on the line
+// that already sets it, or the commented-out line that shows it, or else on
+// a new line at the end.
+func setEnvLines(content []byte, values map[string]string) []byte {
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(content) == 0 {
+		lines = nil
+	}
+	done := make(map[string]bool)
+	for _, active := range []bool{true, false} {
+		for i, line := range lines {
+			for key, value := range values {
+				if done[key] || !envLinePattern(key, active).MatchString(line) {
+					continue
+				}
+				lines[i] = key + "=" + quoteEnv(value)
+				done[key] = true
+			}
+		}
+	}
+	var added []string
+	for key, value := range values {
+		if !done[key] {
+			added = append(added, key+"="+quoteEnv(value))
+		}
+	}
+	if len(added) > 0 {
+		slices.Sort(added)
+		lines = append(lines, "")
+		lines = append(lines, added...)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// envLinePattern matches the line setting key, or with active false the
+// commented-out one, e.g. "# VLLM_PORT=8000".
+func envLinePattern(key string, active bool) *regexp.Regexp {
+	if active {
+		return regexp.MustCompile(`^\s*(export\s+)?` + regexp.QuoteMeta(key) + `\s*=`)
+	}
+	return regexp.MustCompile(`^\s*#\s*` + regexp.QuoteMeta(key) + `\s*=`)
+}
+
+// quoteEnv quotes value for a .env file if it needs it.
+func quoteEnv(value string) string {
+	if strings.ContainsAny(value, " \t#\"'$\\") || (runtime.GOOS == "windows" && strings.Contains(value, "%")) {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// signalMsg reports SIGINT or SIGTERM from outside the TUI, such as the
+// SIGTERM `docker stop` sends.
+type signalMsg struct{ sig os.Signal }
+
+// forwardSignals hands SIGINT and SIGTERM to p as signalMsg, so that they
+// stop the services the way 'q' does; Bubble Tea's own handler would end the
+// program without waiting for them. It returns a function that stops
+// forwarding.
+func forwardSignals(p *tea.Program) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case s := <-sig:
+				p.Send(signalMsg{sig: s})
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateFile is written under logs/ after every run that brings the whole
+// stack up, so a later run can go back to the last setup that worked.
+const stateFile = ".honeyrag-state.json"
+
+// stateConfigKeys are the Model.config entries remembered in the state file:
+// the ones that decide what gets served. Paths, logging and hardware
+// detection are left to the current environment.
+var stateConfigKeys = []string{"model", "gpuUtil", "maxLen", "embedModel", "llmBackend", "ollamaModel"}
+
+// savedState is the last known-good configuration.
+type savedState struct {
+	SavedAt time.Time         `json:"saved_at"`
+	Ports   map[string]string `json:"ports"`
+	Config  map[string]string `json:"config"`
+}
+
+// loadState reads the state file in logsDir. It returns nil if there is none
+// or it can't be parsed.
+func loadState(logsDir string) *savedState {
+	data, err := os.ReadFile(filepath.Join(logsDir, stateFile))
+	if err != nil {
+		return nil
+	}
+	var st savedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil
+	}
+	return &st
+}
+
+// saveState records the running configuration as the last known-good one,
+// with the configured ports rather than any HONEYRAG_AUTO_PORT picked.
+func (m Model) saveState() error {
+	st := savedState{SavedAt: time.Now(), Ports: m.configuredPorts(), Config: make(map[string]string)}
+	for _, key := range stateConfigKeys {
+		st.Config[key] = m.config[key]
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.logsDir, stateFile), append(data, '\n'), 0644)
+}
+
+// stateDiff describes how st differs from the current configuration, one
+// "name: saved (now current)" entry per setting, sorted.
+func (m Model) stateDiff(st *savedState) []string {
+	var diff []string
+	for key, saved := range st.Config {
+		if current, ok := m.config[key]; ok && saved != "" && saved != current {
+			diff = append(diff, fmt.Sprintf("%s: %s (now %s)", key, saved, current))
+		}
+	}
+	for key, saved := range st.Ports {
+		if current := m.port(key); current != "" && saved != "" && saved != current {
+			diff = append(diff, fmt.Sprintf("%s port: %s (now %s)", key, saved, current))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// applyState switches to the saved configuration. It is only called before
+// any step has run, and rebuilds the steps because their descriptions and,
+// for the LLM backend, their shape depend on the config.
+func (m *Model) applyState(st *savedState) {
+	for key, value := range st.Config {
+		if _, ok := m.config[key]; ok && value != "" {
+			m.config[key] = value
+		}
+	}
+	for key, value := range st.Ports {
+		if m.port(key) != "" && validatePort(value) == nil {
+			m.setPort(key, value)
+		}
+	}
+	steps := buildSteps(m.config)
+	for i := range steps {
+		steps[i].Status = m.steps[i].Status
+	}
+	m.steps = steps
+	m.events.event("honeyrag", "using saved state from %s", st.SavedAt.Format(time.RFC3339))
+}
+
+// statePrompt renders the offer to reuse the saved state.
+func (m Model) statePrompt() string {
+	var b strings.Builder
+	b.WriteString(styles.honey.Render(fmt.Sprintf("  Last working setup (%s) differs from configs/.env:", m.prior.SavedAt.Format("2006-01-02 15:04"))))
+	b.WriteString("\n")
+	for _, line := range m.stateDiff(m.prior) {
+		b.WriteString(styles.config.Render("    " + line))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(styles.dim.Render("  Press 'u' to use it or 'enter' to continue with configs/.env"))
+	return b.String()
+}
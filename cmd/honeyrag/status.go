@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type serviceStatus struct {
+	Name          string `json:"name"`
+	Port          string `json:"port"`
+	URL           string `json:"url"`
+	Healthy       bool   `json:"healthy"`
+	PID           int    `json:"pid,omitempty"`
+	UptimeSeconds int64  `json:"uptime_seconds,omitempty"`
+}
+
+type stackStatus struct {
+	Healthy  bool            `json:"healthy"`
+	Services []serviceStatus `json:"services"`
+}
+
+// runStatus implements `honeyrag status`: it health-checks every service
+// using the same configuration as the launcher and exits non-zero if any of
+// them is down.
+func runStatus(m Model, args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print machine-readable JSON")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	status := stackStatus{Healthy: true}
+	for _, svc := range m.activeServices() {
+		s := serviceStatus{
+			Name:    svc.name,
+			Port:    m.port(svc.portKey),
+			URL:     m.healthURL(svc.portKey),
+			Healthy: m.verifyService(ctx, svc.portKey),
+		}
+		if pid, started, ok := runningPID(m.logsDir, svc); ok {
+			s.PID = pid
+			if !started.IsZero() {
+				s.UptimeSeconds = int64(time.Since(started).Seconds())
+			}
+		}
+		status.Healthy = status.Healthy && s.Healthy
+		status.Services = append(status.Services, s)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(status)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SERVICE\tPORT\tSTATUS\tPID\tUPTIME")
+		for _, s := range status.Services {
+			health := "healthy"
+			if !s.Healthy {
+				health = "unhealthy"
+			}
+			pid, uptime := "-", "-"
+			if s.PID != 0 {
+				pid = fmt.Sprint(s.PID)
+				uptime = (time.Duration(s.UptimeSeconds) * time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Port, health, pid, uptime)
+		}
+		w.Flush()
+	}
+
+	if !status.Healthy {
+		return 1
+	}
+	return 0
+}
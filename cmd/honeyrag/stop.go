@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runStop stops services recorded in pid files under logsDir, either all of
+// them or just the ones named. ports and hosts, the services' ports and bind
+// hosts if known, are used to point out a service that is still listening
+// without a pid file, which honeyrag didn't start and won't stop. It returns
+// the process exit code.
+func runStop(logsDir string, ports, hosts map[string]string, names []string) int {
+	selected := make(map[string]bool)
+	for _, name := range names {
+		svc, ok := lookupService(name)
+		if !ok {
+			fmt.Printf("Error: unknown service %q (known: %s)\n", name, serviceNames())
+			return 2
+		}
+		selected[svc.name] = true
+	}
+
+	code := 0
+	for i := len(services) - 1; i >= 0; i-- {
+		svc := services[i]
+		if len(selected) > 0 && !selected[svc.name] {
+			continue
+		}
+		msg, err := stopService(filepath.Join(logsDir, svc.name+".pid"), svc.command, 5*time.Second)
+		if err != nil {
+			fmt.Printf("%-9s %v\n", svc.name+":", err)
+			code = 1
+			continue
+		}
+		if port := ports[svc.portKey]; strings.HasPrefix(msg, "not running") && port != "" && !canListen(hosts[svc.portKey], port) {
+			msg = fmt.Sprintf("no pid file, but port %s is in use by %s; not started by honeyrag, left running", port, describePortOwner(port))
+		}
+		fmt.Printf("%-9s %s\n", svc.name+":", msg)
+	}
+	return code
+}
+
+// stopService terminates the process recorded in pidPath if it is still
+// running command, escalating to a kill after grace. Stale pid files are
+// removed without error.
+func stopService(pidPath, command string, grace time.Duration) (string, error) {
+	data, err := os.ReadFile(pidPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "not running", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		os.Remove(pidPath)
+		return "removed invalid pid file", nil
+	}
+
+	if !processAlive(pid) {
+		os.Remove(pidPath)
+		return "not running (removed stale pid file)", nil
+	}
+
+	cmdline, err := processCommandLine(pid)
+	if err != nil {
+		return "", fmt.Errorf("could not verify pid %d: %v", pid, err)
+	}
+	if !strings.Contains(cmdline, command) {
+		os.Remove(pidPath)
+		return fmt.Sprintf("pid %d belongs to another program (removed stale pid file)", pid), nil
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return "", err
+	}
+	if err := terminateProcess(proc); err != nil {
+		return "", fmt.Errorf("failed to stop pid %d: %v", pid, err)
+	}
+
+	deadline := time.Now().Add(grace)
+	for processAlive(pid) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	msg := fmt.Sprintf("stopped (pid %d)", pid)
+	if processAlive(pid) {
+		killProcess(proc)
+		msg = fmt.Sprintf("killed (pid %d)", pid)
+	}
+
+	os.Remove(pidPath)
+	return msg, nil
+}
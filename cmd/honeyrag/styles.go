@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// styleSet holds the styles the TUI draws with, so that NO_COLOR and
+// --plain can turn them all into no-ops.
+type styleSet struct {
+	// plain is set for the set without colors.
+	plain bool
+
+	title, honey, success, error, waiting, dim, url, log, config, skipped, selected lipgloss.Style
+	// hint marks the explanation of a recognised error, match a search hit
+	// in the log pane, spinner the spinner of a running step.
+	hint, match, spinner lipgloss.Style
+}
+
+var colorStyles = styleSet{
+	title: lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#FFB347")).
+		MarginBottom(1),
+	honey:   lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700")),
+	success: lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00")),
+	error:   lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B")),
+	waiting: lipgloss.NewStyle().Foreground(lipgloss.Color("#87CEEB")),
+	dim:     lipgloss.NewStyle().Foreground(lipgloss.Color("#666666")),
+	url: lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#00BFFF")).
+		Underline(true),
+	log:      lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")),
+	config:   lipgloss.NewStyle().Foreground(lipgloss.Color("#DDA0DD")),
+	skipped:  lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500")),
+	selected: lipgloss.NewStyle().Bold(true),
+	hint:     lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B")).Bold(true),
+	match: lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#000000")).
+		Background(lipgloss.Color("#FFD700")),
+	spinner: lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700")),
+}
+
+// plainStyles only keep the title's spacing and write no escape codes at
+// all; the log pane's search hits are bracketed instead, or they couldn't
+// be told apart.
+var plainStyles = styleSet{
+	plain:    true,
+	title:    lipgloss.NewStyle().MarginBottom(1),
+	honey:    lipgloss.NewStyle(),
+	success:  lipgloss.NewStyle(),
+	error:    lipgloss.NewStyle(),
+	waiting:  lipgloss.NewStyle(),
+	dim:      lipgloss.NewStyle(),
+	url:      lipgloss.NewStyle(),
+	log:      lipgloss.NewStyle(),
+	config:   lipgloss.NewStyle(),
+	skipped:  lipgloss.NewStyle(),
+	selected: lipgloss.NewStyle(),
+	hint:     lipgloss.NewStyle(),
+	match:    lipgloss.NewStyle().Transform(func(s string) string { return "[" + s + "]" }),
+	spinner:  lipgloss.NewStyle(),
+}
+
+// styles is what the TUI draws with: colorStyles unless NO_COLOR or --plain
+// asks for none.
+var styles = colorStyles
+
+// noColor reports whether colors are unwanted: NO_COLOR is set to anything
+// (see no-color.org), or the terminal is a dumb one.
+func noColor() bool {
+	return os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+}
+
+// setDisplay picks what the TUI draws with: ASCII with --ascii, --plain or
+// a terminal that can't show symbols, and no colors with --plain, NO_COLOR
+// or a dumb terminal.
+func setDisplay(ascii, plain bool) {
+	glyphs, styles = unicodeGlyphs, colorStyles
+	if ascii || plain || asciiTerminal() {
+		glyphs = asciiGlyphs
+	}
+	if plain || noColor() {
+		styles = plainStyles
+	}
+}
+
+// newProgressBar builds the download bar, in the honey gradient unless
+// styles are plain.
+func newProgressBar() progress.Model {
+	bar := progress.New(progress.WithGradient("#FFB347", "#FFD700"))
+	if styles.plain {
+		bar = progress.New(progress.WithSolidFill(""))
+		bar.EmptyColor = ""
+	}
+	bar.Full, bar.Empty = glyphs.barFull, glyphs.barEmpty
+	return bar
+}
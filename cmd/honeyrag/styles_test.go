@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// displayViews renders the TUI with the current glyphs and styles before,
+// during and after a run, one view per screen.
+func displayViews(t *testing.T) map[string]string {
+	t.Helper()
+	steps := []Step{
+		testStep("tools", succeed),
+		testStep("embedding", succeed),
+		testStep("lightrag", succeed),
+		testStep("docs", succeed),
+	}
+	steps[2].Service, steps[2].LogFile = "lightrag", "lightrag.log"
+	m := testModel(t, steps)
+	m.width, m.height = 100, 40
+	m.progress.Width = 40
+	views := make(map[string]string)
+
+	m.selected = 0
+	m.steps[0].Status, m.steps[0].StartedAt, m.steps[0].FinishedAt = "done", time.Now(), time.Now()
+	m.steps[1].Status, m.steps[1].StartedAt = "running", time.Now()
+	m.steps[1].Completed, m.steps[1].Total = 1<<20, 4<<20
+	m.steps[1].LogLines = []string{"pulling manifest"}
+	m.steps[3].Status = "skipped"
+	views["running"] = m.View()
+
+	m.selected = -1
+	m.steps[1].Status, m.steps[1].FinishedAt = "error", time.Now()
+	m.err = errors.New("pull failed: connection refused")
+	views["failed"] = m.View()
+
+	for i := range m.steps[:3] {
+		m.steps[i].Status, m.steps[i].FinishedAt = "done", time.Now()
+	}
+	m.err, m.done = nil, true
+	views["done"] = m.View()
+
+	m.logViewQuery = "manifest"
+	m.openLogView(1)
+	views["log pane"] = m.View()
+	if !strings.Contains(views["log pane"], "manifest") {
+		t.Errorf("log pane doesn't show the step's output:\n%s", views["log pane"])
+	}
+
+	views["setup"] = newSetupForm(m.baseDir).View()
+	return views
+}
+
+func TestPlainDisplay(t *testing.T) {
+	savedGlyphs, savedStyles, savedProfile := glyphs, styles, lipgloss.ColorProfile()
+	t.Cleanup(func() {
+		glyphs, styles = savedGlyphs, savedStyles
+		lipgloss.SetColorProfile(savedProfile)
+	})
+	// Render as a true-color terminal would, so any color left in shows.
+	lipgloss.SetColorProfile(termenv.TrueColor)
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		plain  bool
+		colors bool
+		ascii  bool
+	}{
+		{name: "default", colors: true},
+		{name: "NO_COLOR", env: map[string]string{"NO_COLOR": "1"}},
+		{name: "TERM=dumb", env: map[string]string{"TERM": "dumb"}, ascii: true},
+		{name: "--plain", plain: true, ascii: true},
+		{name: "--plain with NO_COLOR", env: map[string]string{"NO_COLOR": "1"}, plain: true, ascii: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM", "xterm-256color")
+			t.Setenv("LC_ALL", "en_US.UTF-8")
+			t.Setenv("NO_COLOR", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			setDisplay(false, tt.plain)
+
+			views := displayViews(t)
+			if !tt.colors {
+				// What reverse video showed, plain text has to.
+				if !strings.Contains(views["log pane"], "[manifest]") {
+					t.Errorf("log pane doesn't mark the search hit:\n%s", views["log pane"])
+				}
+				if !strings.Contains(views["setup"], "Qwen2.5-1.5B-Instruct"+glyphs.caret) {
+					t.Errorf("setup form doesn't show the text cursor:\n%s", views["setup"])
+				}
+			}
+			for screen, view := range views {
+				if got := strings.Contains(view, "\x1b["); got != tt.colors {
+					t.Errorf("%s screen has ANSI escapes: %v, want %v:\n%q", screen, got, tt.colors, view)
+				}
+				nonASCII := strings.IndexFunc(view, func(r rune) bool { return r > unicode.MaxASCII })
+				if tt.ascii && nonASCII >= 0 {
+					t.Errorf("%s screen isn't plain ASCII at %q", screen, view[nonASCII:min(nonASCII+20, len(view))])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// summaryFile is written under logs/ when a run finishes, successfully or
+// not, so a slow or failed run can be compared against a good one.
+const summaryFile = "summary.json"
+
+// runSummary is the contents of summaryFile.
+type runSummary struct {
+	Status     string            `json:"status"`
+	Version    string            `json:"version"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Model      string            `json:"model"`
+	Steps      []stepSummary     `json:"steps"`
+	Endpoints  map[string]string `json:"endpoints,omitempty"`
+	Ports      map[string]string `json:"ports"`
+	Config     map[string]string `json:"config"`
+}
+
+type stepSummary struct {
+	Name       string `json:"name"`
+	Key        string `json:"key"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Info       string `json:"info,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// writeSummary records every step's status and duration and the resolved
+// config in logs/summary.json, along with the endpoints shown once the stack
+// is up and the honeyrag build, for bug reports. err is the failure that
+// ended the run, if any; it is attributed to the failed step.
+func (m Model) writeSummary(err error) error {
+	finished := m.finishedAt
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+	summary := runSummary{
+		Status:     "done",
+		Version:    buildVersion(),
+		StartedAt:  m.startedAt,
+		FinishedAt: finished,
+		Model:      m.chatModel(),
+		Ports:      m.portSnapshot(),
+		Config:     maps.Clone(m.config),
+	}
+	if !m.startedAt.IsZero() {
+		summary.DurationMS = finished.Sub(m.startedAt).Milliseconds()
+	}
+	for _, key := range []string{"vllmAPIKey", "llmAPIKey"} {
+		if summary.Config[key] != "" {
+			summary.Config[key] = "****"
+		}
+	}
+	if err != nil {
+		summary.Status = "error"
+	} else {
+		summary.Endpoints = make(map[string]string)
+		for _, e := range m.endpoints() {
+			summary.Endpoints[e.label] = e.url
+		}
+	}
+
+	for _, step := range m.steps {
+		s := stepSummary{Name: step.Name, Key: step.Key, Status: step.Status, Info: step.Info}
+		if !step.StartedAt.IsZero() && step.Status != "pending" && step.Status != "skipped" {
+			s.DurationMS = step.elapsed().Milliseconds()
+		}
+		if step.Status == "error" && err != nil {
+			s.Error = err.Error()
+		}
+		summary.Steps = append(summary.Steps, s)
+	}
+
+	data, jerr := json.MarshalIndent(summary, "", "  ")
+	if jerr != nil {
+		return jerr
+	}
+	return os.WriteFile(filepath.Join(m.logsDir, summaryFile), append(data, '\n'), 0644)
+}
+
+// buildVersion describes the honeyrag binary: its module version when
+// installed with go install, otherwise the commit it was built from, e.g.
+// "1a2b3c4d5e6f (modified)", or "unknown" without build info.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = " (modified)"
+			}
+		}
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	return revision[:min(len(revision), 12)] + modified
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runTimeoutMsg is sent when --timeout runs out.
+type runTimeoutMsg struct{}
+
+// runDeadline fires runTimeoutMsg once --timeout has passed. dispatchReady
+// starts it with the first step.
+func (m Model) runDeadline() tea.Cmd {
+	if m.runTimeout <= 0 {
+		return nil
+	}
+	return tea.Tick(m.runTimeout, func(time.Time) tea.Msg { return runTimeoutMsg{} })
+}
+
+// runTimeoutError is the error of the steps still running when --timeout
+// runs out.
+func (m Model) runTimeoutError() error {
+	return timeoutError(fmt.Sprintf("global timeout exceeded: the stack was not up within %s (--timeout)", m.runTimeout))
+}
+
+// runTimedOut gives up on a stack that isn't up by --timeout: the steps
+// still running fail with runTimeoutError, or the next one to run if none
+// is, and everything is stopped as on quit, which cancels them and kills
+// their processes.
+func (m Model) runTimedOut() (tea.Model, tea.Cmd) {
+	if m.done || m.quitting {
+		return m, nil
+	}
+	err := m.runTimeoutError()
+	failed := -1
+	for i := range m.steps {
+		if m.steps[i].Status != "running" {
+			continue
+		}
+		m.steps[i].Status = "error"
+		m.steps[i].FinishedAt = time.Now()
+		m.logStep(i, "failed: %s", err)
+		m.emitStep(i, err)
+		if failed < 0 {
+			failed = i
+		}
+	}
+	if failed < 0 && m.err == nil {
+		for i := range m.steps {
+			if m.steps[i].Status == "pending" {
+				m.steps[i].Status = "error"
+				m.logStep(i, "failed: %s", err)
+				m.emitStep(i, err)
+				failed = i
+				break
+			}
+		}
+	}
+	if failed >= 0 {
+		m.err = err
+		m.errHint = nil
+		m.writeSummary(err)
+		m.writeFailure(failed, err)
+	}
+	return m.quit("global timeout exceeded")
+}
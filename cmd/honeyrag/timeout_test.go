@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// blockUntilCancelled is a step that only ends when the run is abandoned.
+func blockUntilCancelled(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// messagesWithin runs cmd, and the commands of any batch it returns, and
+// collects the messages they produce within d.
+func messagesWithin(cmd tea.Cmd, d time.Duration) []tea.Msg {
+	results := make(chan tea.Msg, 16)
+	pending := 0
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd != nil {
+			pending++
+			go func() { results <- cmd() }()
+		}
+	}
+	run(cmd)
+	var msgs []tea.Msg
+	timeout := time.After(d)
+	for pending > 0 {
+		select {
+		case msg := <-results:
+			pending--
+			if batch, ok := msg.(tea.BatchMsg); ok {
+				for _, cmd := range batch {
+					run(cmd)
+				}
+				continue
+			}
+			msgs = append(msgs, msg)
+		case <-timeout:
+			return msgs
+		}
+	}
+	return msgs
+}
+
+func TestRunTimeout(t *testing.T) {
+	m := testModel(t, []Step{
+		testStep("tools", succeed),
+		testStep("vllm", blockUntilCancelled, "tools"),
+	})
+	m.runTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	m = runPipeline(t, m)
+
+	var timeout timeoutError
+	if !errors.As(m.err, &timeout) {
+		t.Fatalf("err = %v, want a timeoutError", m.err)
+	}
+	if elapsed := time.Since(start); elapsed < m.runTimeout {
+		t.Errorf("timed out after %s, before --timeout", elapsed)
+	}
+	if got := stepStatuses(m); got["tools"] != "done" || got["vllm"] != "error" {
+		t.Errorf("statuses = %v, want tools done and vllm failed", got)
+	}
+	if !m.quitting || m.ctx.Err() == nil {
+		t.Error("the run wasn't stopped and its steps not cancelled")
+	}
+	if code := m.failureExitCode(1, m.err); code != failureExitCodes[failureTimeout] {
+		t.Errorf("exit code %d, want %d", code, failureExitCodes[failureTimeout])
+	}
+}
+
+func TestRunTimeoutWaitsForStatePrompt(t *testing.T) {
+	m := testModel(t, []Step{testStep("vllm", blockUntilCancelled)})
+	m.runTimeout = 100 * time.Millisecond
+	m.prior = &savedState{}
+
+	// While the prompt waits for an answer, no deadline is running.
+	for _, msg := range messagesWithin(m.Init(), 3*m.runTimeout) {
+		if _, ok := msg.(runTimeoutMsg); ok {
+			t.Fatal("--timeout ran out while the saved-state prompt was open")
+		}
+	}
+	next, cmd := m.Update(pipelineStartMsg{})
+	m = next.(Model)
+	if cmd != nil || m.steps[0].Status != "pending" || !m.startedAt.IsZero() {
+		t.Fatal("the pipeline started before the saved-state prompt was answered")
+	}
+
+	next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(Model)
+	answered := time.Now()
+	if m.prior != nil || m.startedAt.IsZero() {
+		t.Fatal("answering the prompt didn't start the pipeline")
+	}
+	m = runFrom(t, m, cmd)
+
+	var timeout timeoutError
+	if !errors.As(m.err, &timeout) {
+		t.Fatalf("err = %v, want a timeoutError once dispatched", m.err)
+	}
+	if elapsed := time.Since(answered); elapsed < m.runTimeout {
+		t.Errorf("timed out %s after the prompt was answered, want at least %s", elapsed, m.runTimeout)
+	}
+}
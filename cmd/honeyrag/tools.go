@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// requiredTool is an external program the pipeline shells out to. find, if
+// set, locates it in places other than PATH too.
+type requiredTool struct {
+	name string
+	hint string
+	find func() string
+}
+
+// requiredTools lists the programs the steps need on this machine.
+func (m Model) requiredTools() []requiredTool {
+	return []requiredTool{
+		{name: "uv", hint: uvInstallHint(), find: uvBinary},
+	}
+}
+
+// checkTools is the preflight step that reports every missing tool at once
+// instead of failing on the first one halfway through the pipeline.
+// uv is installed here if the user agreed to it, and the disk is checked for
+// room for the dependencies and models to come.
+func (m Model) checkTools(ctx context.Context, index int) error {
+	if uvBinary() == "" && m.consent.uvInstall {
+		if m.dryRun {
+			archive, _ := uvArchive()
+			prefix, _ := userPrefix()
+			m.showAction("download %s%s and put uv in %s", uvReleaseURL, archive, filepath.Join(prefix, "bin"))
+			return nil
+		}
+		path, err := installUV(ctx, func(completed, total int64) {
+			m.notifier.notify(stepProgressMsg{index: index, completed: completed, total: total})
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to install uv: %v. Install it from https://docs.astral.sh/uv/getting-started/installation/ and retry", err)
+		}
+		m.notifier.notify(logUpdateMsg{index: index, line: "installed " + path})
+	}
+
+	var missing []string
+	for _, tool := range m.requiredTools() {
+		if tool.find != nil && tool.find() != "" {
+			continue
+		}
+		if _, err := exec.LookPath(tool.name); err != nil {
+			missing = append(missing, fmt.Sprintf("  %s: %s", tool.name, tool.hint))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("missing required tools:\n" + strings.Join(missing, "\n"))
+	}
+	return m.checkDiskSpace()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// usageInterval is how often the done screen's resource figures refresh.
+const usageInterval = 3 * time.Second
+
+// serviceUsage is what one service uses, summed over its process group
+// (uv run starts the server itself as a grandchild).
+type serviceUsage struct {
+	// known is false when honeyrag has no pid for the service, e.g. one it
+	// found already running without a pid file.
+	known bool
+	rss   uint64
+	// cpu is a percentage of one core, negative until there are two
+	// samples to compare.
+	cpu float64
+	// gpu is the GPU memory its processes hold, if nvidia-smi reports any.
+	gpu    uint64
+	hasGPU bool
+}
+
+// cpuSample is a process group's CPU time at one moment.
+type cpuSample struct {
+	ticks uint64
+	at    time.Time
+}
+
+type usageTickMsg struct{}
+
+// usageMsg carries a fresh sample; samples are the CPU times it was taken
+// from, to compare the next one with.
+type usageMsg struct {
+	usage   map[string]serviceUsage
+	samples map[int]cpuSample
+}
+
+func (m Model) usageTick() tea.Cmd {
+	if !usageSupported {
+		return nil
+	}
+	return tea.Tick(usageInterval, func(time.Time) tea.Msg { return usageTickMsg{} })
+}
+
+// sampleUsage measures every service step that has run, off the Update
+// goroutine.
+func (m Model) sampleUsage() tea.Cmd {
+	if !usageSupported {
+		return nil
+	}
+	pids := make(map[string]int)
+	for _, step := range m.steps {
+		svc, ok := lookupService(step.Service)
+		if ok && !step.External && !m.remote(svc.portKey) && (step.Status == "done" || step.Status == "error") {
+			pids[step.Service] = m.processes.pid(step.Service)
+		}
+	}
+	prev := m.usageSamples
+	gpu := m.config["device"] == deviceCUDA
+	return func() tea.Msg {
+		var gpuMem map[int]uint64
+		if gpu {
+			gpuMem = gpuMemoryByPID()
+		}
+		msg := usageMsg{usage: make(map[string]serviceUsage), samples: make(map[int]cpuSample)}
+		for name, pid := range pids {
+			if pid == 0 {
+				msg.usage[name] = serviceUsage{}
+				continue
+			}
+			members, rss, ticks := groupUsage(pid)
+			now := cpuSample{ticks: ticks, at: time.Now()}
+			u := serviceUsage{known: len(members) > 0, rss: rss, cpu: -1}
+			if last, ok := prev[pid]; ok && now.at.After(last.at) && ticks >= last.ticks {
+				u.cpu = float64(ticks-last.ticks) / clockTicks / now.at.Sub(last.at).Seconds() * 100
+			}
+			for _, member := range members {
+				if mem, ok := gpuMem[member]; ok {
+					u.gpu += mem
+					u.hasGPU = true
+				}
+			}
+			msg.usage[name] = u
+			msg.samples[pid] = now
+		}
+		return msg
+	}
+}
+
+// gpuMemoryByPID asks nvidia-smi how much GPU memory each process holds. It
+// returns nil if it can't tell.
+func gpuMemoryByPID() map[int]uint64 {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "nvidia-smi", "--query-compute-apps=pid,used_memory", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil
+	}
+	mem := make(map[int]uint64)
+	for _, line := range strings.Split(string(out), "\n") {
+		pid, used, ok := strings.Cut(line, ",")
+		if !ok {
+			continue
+		}
+		p, err1 := strconv.Atoi(strings.TrimSpace(pid))
+		mib, err2 := strconv.ParseUint(strings.TrimSpace(used), 10, 64)
+		if err1 == nil && err2 == nil {
+			mem[p] += mib << 20
+		}
+	}
+	return mem
+}
+
+// usageView renders a service's resource use for its step line, e.g.
+// "1.2 GB · 35% CPU · GPU 7.8 GB", dimmed when its pid is unknown.
+func (m Model) usageView(service string) string {
+	u, ok := m.usage[service]
+	if !ok {
+		return ""
+	}
+	if !u.known {
+		return styles.dim.Render("  mem/cpu: pid unknown")
+	}
+	parts := []string{formatBytes(int64(u.rss))}
+	if u.cpu >= 0 {
+		parts = append(parts, fmt.Sprintf("%.0f%% CPU", u.cpu))
+	}
+	if u.hasGPU {
+		parts = append(parts, "GPU "+formatBytes(int64(u.gpu)))
+	}
+	return "  " + styles.config.Render(strings.Join(parts, glyphs.sep))
+}
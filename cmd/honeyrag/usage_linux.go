@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// usageSupported says whether groupUsage can measure anything here.
+const usageSupported = true
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc; it is
+// 100 on every architecture Linux runs vLLM on.
+const clockTicks = 100
+
+// groupUsage sums, from /proc/<pid>/stat and statm, the resident memory and
+// CPU time of every process in the process group led by pgid, and lists
+// them.
+func groupUsage(pgid int) (members []int, rss, ticks uint64) {
+	want := strconv.Itoa(pgid)
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name in parentheses may hold spaces; the fields
+		// after it are state, ppid, pgrp, ..., utime (14) and stime (15).
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 13 || fields[2] != want {
+			continue
+		}
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		ticks += utime + stime
+
+		if statm, err := os.ReadFile(filepath.Join(dir, "statm")); err == nil {
+			if f := strings.Fields(string(statm)); len(f) > 1 {
+				pages, _ := strconv.ParseUint(f[1], 10, 64)
+				rss += pages * uint64(os.Getpagesize())
+			}
+		}
+		pid, _ := strconv.Atoi(filepath.Base(dir))
+		members = append(members, pid)
+	}
+	return members, rss, ticks
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// uvSyncBackoff is how long uvSync waits before each retry of `uv sync` with
+// the same Python version, so that a network hiccup during a download doesn't
+// fail the step. Once the retries run out it moves on to the next version.
+var uvSyncBackoff = []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}
+
+// uvSyncErrorLines is how much of a failed attempt's output goes into the
+// step's error.
+const uvSyncErrorLines = 10
+
+// uvSyncPhase turns a line of `uv sync` output into the step's Info, or ""
+// for lines that don't mark progress. uv reports each stage on a line of its
+// own, e.g. "Resolved 180 packages in 1.2s" or "Downloading torch (846.1MiB)".
+func uvSyncPhase(line string) string {
+	line = strings.TrimSpace(line)
+	for _, prefix := range []string{"Resolved", "Downloading", "Downloaded", "Building", "Built", "Prepared", "Installed", "Uninstalled", "Audited"} {
+		if strings.HasPrefix(line, prefix+" ") {
+			return line
+		}
+	}
+	return ""
+}
+
+// uvSyncFailure is one failed `uv sync` run, kept so the step can report the
+// most useful of them once every Python version has been tried.
+type uvSyncFailure struct {
+	python string
+	err    error
+	output string
+}
+
+// missingPython reports whether uv failed because it couldn't find or fetch
+// the requested interpreter, rather than while installing dependencies.
+// Retrying won't help and the error says little about the project.
+func (f uvSyncFailure) missingPython() bool {
+	out := strings.ToLower(f.output)
+	return strings.Contains(out, "no interpreter found") ||
+		strings.Contains(out, "no download found for request")
+}
+
+// mostInformative picks the failure to show: the last one that got as far
+// as installing dependencies, or the last one of all if none did.
+func mostInformative(failures []uvSyncFailure) uvSyncFailure {
+	for i := len(failures) - 1; i >= 0; i-- {
+		if !failures[i].missingPython() {
+			return failures[i]
+		}
+	}
+	return failures[len(failures)-1]
+}
+
+// pythonLabel names the interpreter uv was asked for in messages.
+func pythonLabel(version string) string {
+	if version == "" {
+		return "with the default Python"
+	}
+	return "with Python " + version
+}
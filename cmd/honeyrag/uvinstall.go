@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// uvReleaseURL is where uv's standalone release archives and their .sha256
+// files are published.
+const uvReleaseURL = "https://github.com/astral-sh/uv/releases/latest/download/"
+
+// uvArchive names the standalone release archive for this OS and
+// architecture.
+func uvArchive() (string, bool) {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[runtime.GOARCH]
+	if arch == "" {
+		return "", false
+	}
+	switch runtime.GOOS {
+	case "linux":
+		return "uv-" + arch + "-unknown-linux-gnu.tar.gz", true
+	case "darwin":
+		return "uv-" + arch + "-apple-darwin.tar.gz", true
+	}
+	return "", false
+}
+
+// uvBinary returns the uv executable to run: the one on PATH, or the one a
+// previous run installed under userPrefix if that isn't on PATH. It returns
+// "" if there is neither.
+func uvBinary() string {
+	if path, err := exec.LookPath("uv"); err == nil {
+		return path
+	}
+	if prefix, err := userPrefix(); err == nil {
+		path := filepath.Join(prefix, "bin", "uv")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// uvCommand is the program to run uv as: uvBinary, or plain "uv" so a
+// missing install fails with the usual not-found error.
+func uvCommand() string {
+	if path := uvBinary(); path != "" {
+		return path
+	}
+	return "uv"
+}
+
+// uvInstallHint is the advice shown when uv is missing and honeyrag won't or
+// can't install it.
+func uvInstallHint() string {
+	const docs = "see https://docs.astral.sh/uv/getting-started/installation/"
+	switch runtime.GOOS {
+	case "windows":
+		return `install it with powershell -ExecutionPolicy ByPass -c "irm https://astral.sh/uv/install.ps1 | iex" (` + docs + ")"
+	case "darwin":
+		return "install it with brew install uv, or re-run with --yes to let honeyrag download it (" + docs + ")"
+	}
+	return "re-run with --yes to let honeyrag download it, or install it yourself (" + docs + ")"
+}
+
+// confirmUVInstall asks on the terminal, before the TUI takes it over,
+// whether uv may be downloaded. It returns false without asking when uv is
+// already available or can't be installed here.
+func confirmUVInstall() bool {
+	if uvBinary() != "" {
+		return false
+	}
+	if _, ok := uvArchive(); !ok {
+		return false
+	}
+	prefix, err := userPrefix()
+	if err != nil {
+		return false
+	}
+	return confirm(fmt.Sprintf("uv is not installed. Download the official release to %s?", filepath.Join(prefix, "bin")))
+}
+
+// installUV downloads the standalone uv release for this platform, checks it
+// against its published SHA256 and puts uv and uvx in userPrefix/bin.
+// onProgress is called with the bytes downloaded so far. It returns the path
+// of the installed binary.
+func installUV(ctx context.Context, onProgress func(completed, total int64)) (string, error) {
+	archive, ok := uvArchive()
+	if !ok {
+		return "", fmt.Errorf("no uv release for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	prefix, err := userPrefix()
+	if err != nil {
+		return "", err
+	}
+
+	want, err := uvChecksum(ctx, archive)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpGet(ctx, uvReleaseURL+archive)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The archive is a few tens of MB; hold it in memory rather than in a
+	// temporary file.
+	var buf bytes.Buffer
+	hash := sha256.New()
+	progress := &progressWriter{total: resp.ContentLength, onProgress: onProgress}
+	if _, err := io.Copy(io.MultiWriter(&buf, hash, progress), resp.Body); err != nil {
+		return "", fmt.Errorf("downloading %s: %v", archive, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", archive, got, want)
+	}
+
+	bin := filepath.Join(prefix, "bin")
+	if err := extractUV(&buf, bin); err != nil {
+		return "", fmt.Errorf("unpacking %s: %v", archive, err)
+	}
+	return filepath.Join(bin, "uv"), nil
+}
+
+// uvChecksum fetches archive's <archive>.sha256, whose single line reads
+// "<hex> *<archive>".
+func uvChecksum(ctx context.Context, archive string) (string, error) {
+	resp, err := httpGet(ctx, uvReleaseURL+archive+".sha256")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("no checksum in %s.sha256", archive)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// extractUV copies the uv and uvx executables out of a release archive,
+// where they sit in a directory named after the archive, into bin.
+func extractUV(r io.Reader, bin string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	found := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || (name != "uv" && name != "uvx") {
+			continue
+		}
+		if err := writeFile(filepath.Join(bin, name), tr, 0755); err != nil {
+			return err
+		}
+		found = found || name == "uv"
+	}
+	if !found {
+		return fmt.Errorf("no uv executable in archive")
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vllmOOMRetries is how many times startVLLM retries with smaller memory
+// settings after vLLM runs out of GPU memory.
+const vllmOOMRetries = 2
+
+// errVLLMOOM is returned by runVLLM when vLLM died of GPU memory exhaustion.
+var errVLLMOOM = errors.New("vLLM ran out of GPU memory")
+
+// isOOMLine reports whether a vLLM log line signals GPU memory exhaustion,
+// either while loading the weights or when sizing the KV cache.
+func isOOMLine(line string) bool {
+	line = strings.ToLower(line)
+	return strings.Contains(line, "out of memory") ||
+		strings.Contains(line, "outofmemoryerror") ||
+		strings.Contains(line, "no available memory for the cache blocks")
+}
+
+// isKilledLine reports whether a vLLM log line is the "Killed" a shell or
+// worker prints when the kernel's out-of-memory killer ends a process.
+func isKilledLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "Killed" || strings.HasSuffix(line, " Killed") ||
+		strings.Contains(strings.ToLower(line), "oom-kill") || strings.Contains(line, "killed by signal 9")
+}
+
+// vllmKilledError explains a vLLM killed outright while starting, which
+// short of someone running kill -9 means the machine ran out of RAM: vLLM
+// loads the weights through host memory. evidence is the log line that
+// gave it away, or else the exit status.
+func vllmKilledError(evidence, logPath string) error {
+	return fmt.Errorf("vLLM was killed while starting, most likely by the kernel's out-of-memory killer "+
+		"(the machine ran out of RAM, not VRAM): %s\n"+
+		"Lower --max-model-len (VLLM_MAX_MODEL_LEN) or --gpu-memory-utilization (VLLM_GPU_MEMORY_UTILIZATION) in configs/.env, "+
+		"choose a smaller VLLM_MODEL, or close programs using memory. Last logs:\n%s",
+		evidence, readLastLines(logPath, 20))
+}
+
+// oomFallback returns the settings for the next attempt after an OOM: the
+// next step down the 0.6 → 0.5 utilization ladder and half the context
+// length, down to 512 tokens. ok is false when neither can shrink further.
+func oomFallback(gpuUtil, maxLen string) (string, string, bool) {
+	changed := false
+	if util, err := strconv.ParseFloat(gpuUtil, 64); err == nil {
+		for _, lower := range []float64{0.6, 0.5} {
+			if lower < util {
+				gpuUtil, changed = strconv.FormatFloat(lower, 'f', -1, 64), true
+				break
+			}
+		}
+	}
+	if length, err := strconv.Atoi(maxLen); err == nil && length/2 >= 512 {
+		maxLen, changed = strconv.Itoa(length/2), true
+	}
+	return gpuUtil, maxLen, changed
+}
+
+// quantizationPattern matches vLLM quantization method names such as awq,
+// gptq_marlin or compressed-tensors.
+var quantizationPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// vllmExtraArgs returns the optional vllm serve flags from config: the
+// dedicated VLLM_TENSOR_PARALLEL, VLLM_QUANTIZATION and VLLM_API_KEY
+// settings, followed by VLLM_EXTRA_ARGS split like a shell would.
+func vllmExtraArgs(config map[string]string) ([]string, error) {
+	var args []string
+	if v := config["tensorParallel"]; v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 {
+			return nil, fmt.Errorf("VLLM_TENSOR_PARALLEL must be a positive number of GPUs, got %q", v)
+		}
+		args = append(args, "--tensor-parallel-size", v)
+	}
+	if v := config["quantization"]; v != "" {
+		if !quantizationPattern.MatchString(v) {
+			return nil, fmt.Errorf("VLLM_QUANTIZATION must be a method name such as awq or gptq, got %q", v)
+		}
+		args = append(args, "--quantization", v)
+	}
+	if v := config["vllmAPIKey"]; v != "" {
+		if strings.ContainsAny(v, " \t\n") {
+			return nil, errors.New("VLLM_API_KEY must not contain whitespace")
+		}
+		args = append(args, "--api-key", v)
+	}
+	extra, err := splitArgs(config["vllmExtraArgs"])
+	if err != nil {
+		return nil, fmt.Errorf("VLLM_EXTRA_ARGS: %v", err)
+	}
+	return append(args, extra...), nil
+}
+
+// splitArgs splits s into words the way a POSIX shell would, without any
+// expansion: whitespace separates words, single quotes keep everything
+// literally, and backslashes escape the next character outside single
+// quotes (inside double quotes only before " \ $ and `).
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune(`"\$`+"`", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// maskAPIKey returns args with the value of any --api-key flag hidden, for
+// display.
+func maskAPIKey(args []string) []string {
+	masked := make([]string, len(args))
+	copy(masked, args)
+	for i, arg := range masked {
+		if arg == "--api-key" && i+1 < len(masked) {
+			masked[i+1] = "****"
+		} else if strings.HasPrefix(arg, "--api-key=") {
+			masked[i] = "--api-key=****"
+		}
+	}
+	return masked
+}
+
+// vllmConfigView is shown under the vLLM step: the model and the settings it
+// is served with.
+func (m Model) vllmConfigView() string {
+	device := "Device: cpu"
+	if m.config["device"] != deviceCPU {
+		device = fmt.Sprintf("Device: %s | GPU: %s", m.config["device"], m.config["gpuUtil"])
+	}
+	extra, _ := vllmExtraArgs(m.config)
+	if m.config["device"] != deviceCPU {
+		device += " | Tensor parallel: " + tensorParallelSize(extra)
+	}
+	view := styles.config.Render(fmt.Sprintf("    Backend: vLLM | Model: %s | %s | Context: %s",
+		m.config["model"], device, m.config["maxLen"]))
+	if len(extra) > 0 {
+		view += "\n" + styles.config.Render("    Args: "+strings.Join(maskAPIKey(extra), " "))
+	}
+	return view
+}
+
+// tensorParallelSize returns the number of GPUs vLLM splits the model
+// across with args, the optional flags from vllmExtraArgs: the last
+// --tensor-parallel-size (or -tp) given, since that is the one vLLM uses.
+func tensorParallelSize(args []string) string {
+	size := "1"
+	for i, arg := range args {
+		switch {
+		case (arg == "--tensor-parallel-size" || arg == "-tp") && i+1 < len(args):
+			size = args[i+1]
+		case strings.HasPrefix(arg, "--tensor-parallel-size="):
+			size = strings.TrimPrefix(arg, "--tensor-parallel-size=")
+		}
+	}
+	return size
+}
+
+// vllmServedModels parses vLLM's /v1/models answer into the names it serves
+// each model under: the served name and the model it was loaded from. ok is
+// false if body isn't a model list.
+func vllmServedModels(body []byte) (served []string, ok bool) {
+	var models struct {
+		Data []struct {
+			ID   string `json:"id"`
+			Root string `json:"root"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &models) != nil || models.Data == nil {
+		return nil, false
+	}
+	for _, model := range models.Data {
+		served = append(served, model.ID)
+		if model.Root != "" && model.Root != model.ID {
+			served = append(served, model.Root)
+		}
+	}
+	return served, true
+}
+
+// vllmPhasePercent finds the percentage on a tqdm progress line.
+var vllmPhasePercent = regexp.MustCompile(`(\d+)%(?: Completed)? ?\|`)
+
+// vllmPhase recognises the log lines that mark vLLM's way through startup
+// and describes the stage it has reached, or returns "" for other lines.
+// Downloads and weight loading carry their progress bar's percentage.
+func vllmPhase(line string) string {
+	lower := strings.ToLower(line)
+	var phase string
+	switch {
+	case strings.Contains(lower, "fetching") && strings.Contains(lower, "files"),
+		strings.Contains(lower, "downloading"):
+		phase = "downloading model files"
+	case strings.Contains(lower, "loading safetensors"), strings.Contains(lower, "loading weights"),
+		strings.Contains(lower, "loading model weights"):
+		phase = "loading weights to GPU"
+	case strings.Contains(lower, "memory profiling"), strings.Contains(lower, "gpu blocks"),
+		strings.Contains(lower, "kv cache"):
+		phase = "sizing the KV cache"
+	case strings.Contains(lower, "cuda graph"), strings.Contains(lower, "cudagraph"):
+		phase = "capturing CUDA graphs"
+	case strings.Contains(lower, "starting vllm api server"), strings.Contains(lower, "application startup"):
+		phase = "starting the API server"
+	default:
+		return ""
+	}
+	if match := vllmPhasePercent.FindStringSubmatch(line); match != nil {
+		phase += " " + match[1] + "%"
+	}
+	return phase
+}
+
+// vllmWarmup sends vLLM one-token completions until one succeeds. Some
+// setups list the model on /v1/models well before they can generate, and
+// LightRAG's first request would fail in that gap. It gives up after
+// VLLM_WARMUP_TIMEOUT or when proc exits, and returns how long the
+// successful request took: the time to the first token.
+func (m Model) vllmWarmup(ctx context.Context, index int, proc *managedProcess) (time.Duration, error) {
+	grace := m.timeouts["vllmWarmup"]
+	deadline := time.Now().Add(grace)
+	m.notifier.notify(stepDeadlineMsg{index: index, deadline: deadline})
+	m.notifier.notify(logUpdateMsg{index: index, line: "model listed, waiting for a first completion", info: "warming up"})
+
+	// --served-model-name in VLLM_EXTRA_ARGS renames the model.
+	name := m.config["model"]
+	if list, ok := fetchHealth(ctx, m.healthURL("vllm"), m.config["vllmAPIKey"], m.probe.timeout); ok {
+		if served, ok := vllmServedModels(list); ok && len(served) > 0 {
+			name = served[0]
+		}
+	}
+	body, _ := json.Marshal(map[string]any{"model": name, "prompt": "Hello", "max_tokens": 1})
+	for {
+		started := time.Now()
+		err := m.vllmComplete(ctx, deadline, body)
+		if err == nil {
+			return time.Since(started), nil
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if time.Now().After(deadline) {
+			return 0, timeoutError(fmt.Sprintf("listed the model but could not complete a prompt within %s (VLLM_WARMUP_TIMEOUT): %v", grace, err))
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-proc.done:
+			return 0, fmt.Errorf("exited during warm-up (%v)", proc.cmd.ProcessState)
+		case <-time.After(m.probe.interval):
+		}
+	}
+}
+
+// vllmComplete posts body to vLLM's /v1/completions, giving up at deadline,
+// and fails unless it answers 200 with at least one choice.
+func (m Model) vllmComplete(ctx context.Context, deadline time.Time, body []byte) error {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.serviceURL("vllm", "/v1/completions"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := m.config["vllmAPIKey"]; key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return err
+	}
+	var completion struct {
+		Choices []json.RawMessage `json:"choices"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&completion); err != nil {
+		return fmt.Errorf("POST /v1/completions: %v", err)
+	}
+	if len(completion.Choices) == 0 {
+		return errors.New("POST /v1/completions returned no choices")
+	}
+	return nil
+}
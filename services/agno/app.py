@@ -5,7 +5,7 @@ Web UI + API for the LightRAG-connected agent.
 
 Part of the HoneyRAG stack: A sweet, fully-integrated local RAG system.
 
-Run: uv run uvicorn app:app --host 0.0.0.0 --port 8081
+Run: uv run uvicorn app:app --host 127.0.0.1 --port 8081
 UI:  http://localhost:8081
 """
 
@@ -20,10 +20,17 @@ from agno.os import AgentOS
 # Config (from environment or defaults)
 # -----------------------------------------------------------------------------
 VLLM_MODEL = os.getenv("VLLM_MODEL", "Qwen/Qwen3-8B")
+VLLM_PORT = os.getenv("VLLM_PORT", "8000")
+# OpenAI-compatible endpoint serving the model; the launcher points this at
+# Ollama when HONEYRAG_LLM_BACKEND=ollama
+LLM_BASE_URL = os.getenv("LLM_BASE_URL", f"http://localhost:{VLLM_PORT}/v1")
 LIGHTRAG_PORT = os.getenv("LIGHTRAG_PORT", "9621")
 AGNO_PORT = int(os.getenv("AGNO_PORT", "8081"))
+# Address to listen on; 0.0.0.0 makes the UI reachable from other machines
+AGNO_HOST = os.getenv("AGNO_HOST", "127.0.0.1")
 
-LIGHTRAG_URL = f"http://localhost:{LIGHTRAG_PORT}"
+# The launcher sets LIGHTRAG_URL when LIGHTRAG_HOST points at another machine
+LIGHTRAG_URL = os.getenv("LIGHTRAG_URL", f"http://localhost:{LIGHTRAG_PORT}")
 
 # -----------------------------------------------------------------------------
 # LightRAG Vector DB
@@ -49,7 +56,8 @@ agent = Agent(
     name="HoneyRAG Agent",
     model=VLLM(
         id=VLLM_MODEL,
-        api_key="not-needed",
+        base_url=LLM_BASE_URL,
+        api_key=os.getenv("VLLM_API_KEY") or "not-needed",
         enable_thinking=False,
     ),
     knowledge=knowledge,
@@ -83,4 +91,4 @@ if __name__ == "__main__":
     - UI: http://localhost:8081
     - API Docs: http://localhost:8081/docs
     """
-    agent_os.serve(app="app:app", host="0.0.0.0", port=AGNO_PORT, reload=True)
+    agent_os.serve(app="app:app", host=AGNO_HOST, port=AGNO_PORT, reload=True)